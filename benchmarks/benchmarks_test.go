@@ -2,11 +2,15 @@ package tarfs
 
 import (
 	"archive/tar"
+	"bytes"
+	"fmt"
 	"io"
 	"io/fs"
 	"math/rand"
 	"os"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/nlepage/go-tarfs"
 )
@@ -91,6 +95,128 @@ func benchmarkReadFile(b *testing.B, tarFileName string) {
 	}
 }
 
+func BenchmarkReadFile_Remote(b *testing.B) {
+	benchmarkReadFileRemote(b)
+}
+
+func BenchmarkReadFile_RemoteParallel(b *testing.B) {
+	benchmarkReadFileRemote(b, tarfs.WithParallelReads(4<<20, 8))
+}
+
+func BenchmarkWriteTo_RemoteParallel(b *testing.B) {
+	tf, err := os.Open("few-large-files.tar")
+	if err != nil {
+		panic(err)
+	}
+	defer tf.Close()
+
+	tfs, err := tarfs.New(newRemoteReader(tf), tarfs.WithParallelReads(4<<20, 8))
+	if err != nil {
+		panic(err)
+	}
+
+	fileName := randomFileName["few-large-files.tar"]
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f, err := tfs.Open(fileName)
+		if err != nil {
+			panic(err)
+		}
+		n, err := io.Copy(io.Discard, f)
+		if err != nil {
+			panic(err)
+		}
+		b.SetBytes(n)
+		if err := f.Close(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func benchmarkReadFileRemote(b *testing.B, opts ...tarfs.Option) {
+	tf, err := os.Open("few-large-files.tar")
+	if err != nil {
+		panic(err)
+	}
+	defer tf.Close()
+
+	tfs, err := tarfs.New(newRemoteReader(tf), opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	fileName := randomFileName["few-large-files.tar"]
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data, err := fs.ReadFile(tfs, fileName)
+		if err != nil {
+			panic(err)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+// remoteReader simulates a remote file, each read has a latency of 2ms and a bandwidth of 200MB/s.
+type remoteReader struct {
+	*io.SectionReader
+}
+
+func newRemoteReader(f *os.File) *remoteReader {
+	st, err := f.Stat()
+	if err != nil {
+		panic(err)
+	}
+	return &remoteReader{io.NewSectionReader(f, 0, st.Size())}
+}
+
+func (r *remoteReader) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(2*time.Millisecond + time.Duration(len(p))*time.Second/200e6)
+	return r.SectionReader.ReadAt(p, off)
+}
+
+func BenchmarkIndex_MemoryPerEntry(b *testing.B) {
+	const numFiles = 100000
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for i := 0; i < numFiles; i++ {
+		if err := w.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf("dir%03d/subdir%02d/file%06d.txt", i%1000, i%17, i),
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     0,
+		}); err != nil {
+			panic(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	b.ResetTimer()
+
+	var before, after runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		tfs, err := tarfs.New(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			panic(err)
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(tfs)
+
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/numFiles, "B/entry")
+	}
+}
+
 func openTarThenReadFile(tarName, fileName string) {
 	tf, err := os.Open(tarName)
 	if err != nil {
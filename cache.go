@@ -0,0 +1,209 @@
+package tarfs
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// Defaults of CacheOptions
+const (
+	defaultCacheBlockSize = 64 << 10
+	defaultCacheBudget    = 64 << 20
+)
+
+// CacheOptions configures the block cache of WithCache.
+type CacheOptions struct {
+	// BlockSize is the size of the cached blocks, 64KiB if zero.
+	BlockSize int64
+
+	// Budget is the maximum number of bytes held by the cache, 64MiB if zero.
+	Budget int64
+
+	// ReadAhead is the number of blocks read in advance when the archive is read sequentially.
+	ReadAhead int
+}
+
+// WithCache caches the archive read by New in fixed-size aligned blocks,
+// which are evicted in least recently used order once the cache exceeds its budget.
+// Adjacent blocks missing from the cache are read with one call to ReadAt.
+// This is useful when the io.ReaderAt given to New is slow, such as a network reader, see NewHTTP.
+// The cache is not used for archives held in memory.
+func WithCache(opts *CacheOptions) Option {
+	if opts == nil {
+		opts = &CacheOptions{}
+	}
+	return func(o *options) {
+		o.cache = opts
+	}
+}
+
+// blockCache is an io.ReaderAt caching the blocks of ra.
+type blockCache struct {
+	ra        io.ReaderAt
+	size      int64 // -1 if unknown
+	blockSize int64
+	maxBlocks int
+	readAhead int
+
+	mu     sync.Mutex
+	blocks map[int64]*list.Element // values of elements are *cacheBlock
+	lru    *list.List
+	// last is the index of the last block read, to detect sequential reads
+	last int64
+}
+
+type cacheBlock struct {
+	index int64
+	// data is shorter than the block size for the last block of ra
+	data []byte
+}
+
+func newBlockCache(ra io.ReaderAt, size int64, opts *CacheOptions) *blockCache {
+	blockSize := opts.BlockSize
+	if blockSize <= 0 {
+		blockSize = defaultCacheBlockSize
+	}
+	budget := opts.Budget
+	if budget <= 0 {
+		budget = defaultCacheBudget
+	}
+
+	return &blockCache{
+		ra:        ra,
+		size:      size,
+		blockSize: blockSize,
+		maxBlocks: int(max(budget/blockSize, 1)),
+		readAhead: opts.ReadAhead,
+		blocks:    make(map[int64]*list.Element),
+		lru:       list.New(),
+		last:      -1,
+	}
+}
+
+var _ io.ReaderAt = &blockCache{}
+
+func (c *blockCache) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if c.size >= 0 && off >= c.size {
+		return 0, io.EOF
+	}
+
+	first, last := off/c.blockSize, (off+int64(len(p))-1)/c.blockSize
+	if c.size >= 0 {
+		last = min(last, (c.size-1)/c.blockSize)
+	}
+
+	blocks, missing := c.lookup(first, last)
+
+	if len(missing) != 0 {
+		fetched, err := c.fetch(missing)
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range fetched {
+			if b.index <= last {
+				blocks[b.index-first] = b.data
+			}
+		}
+	}
+
+	n := 0
+	for i, data := range blocks {
+		start := max(off-(first+int64(i))*c.blockSize, 0)
+		if start >= int64(len(data)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[start:])
+		if int64(len(data)) < c.blockSize && n < len(p) {
+			return n, io.EOF
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// lookup returns the cached blocks from first to last, nil for blocks which are not cached,
+// and the indexes of the blocks to fetch, including read-ahead blocks.
+func (c *blockCache) lookup(first, last int64) ([][]byte, []int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sequential := first == c.last || first == c.last+1
+	c.last = last
+
+	blocks := make([][]byte, last-first+1)
+	var missing []int64
+
+	for i := first; i <= last; i++ {
+		if elt, ok := c.blocks[i]; ok {
+			c.lru.MoveToFront(elt)
+			blocks[i-first] = elt.Value.(*cacheBlock).data
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	if sequential && len(missing) != 0 {
+		for i := last + 1; i <= last+int64(c.readAhead); i++ {
+			if c.size >= 0 && i*c.blockSize >= c.size {
+				break
+			}
+			if _, ok := c.blocks[i]; !ok {
+				missing = append(missing, i)
+			}
+		}
+	}
+
+	return blocks, missing
+}
+
+// fetch reads the blocks of indexes, which are sorted, coalescing adjacent blocks, and caches them.
+func (c *blockCache) fetch(indexes []int64) ([]*cacheBlock, error) {
+	var fetched []*cacheBlock
+
+	for len(indexes) != 0 {
+		n := 1
+		for n < len(indexes) && indexes[n] == indexes[n-1]+1 {
+			n++
+		}
+
+		buf := make([]byte, int64(n)*c.blockSize)
+		read, err := c.ra.ReadAt(buf, indexes[0]*c.blockSize)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = buf[:read]
+
+		for _, index := range indexes[:n] {
+			size := min(c.blockSize, int64(len(buf)))
+			fetched = append(fetched, &cacheBlock{index, buf[:size:size]})
+			buf = buf[size:]
+		}
+
+		indexes = indexes[n:]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, b := range fetched {
+		if elt, ok := c.blocks[b.index]; ok {
+			c.lru.MoveToFront(elt)
+			continue
+		}
+		c.blocks[b.index] = c.lru.PushFront(b)
+		for c.lru.Len() > c.maxBlocks {
+			evicted := c.lru.Remove(c.lru.Back()).(*cacheBlock)
+			delete(c.blocks, evicted.index)
+		}
+	}
+
+	return fetched, nil
+}
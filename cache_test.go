@@ -0,0 +1,147 @@
+package tarfs
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReaderAt records the calls to ReadAt.
+type countingReaderAt struct {
+	*bytes.Reader
+
+	mu    sync.Mutex
+	calls [][2]int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, [2]int64{off, int64(len(p))})
+	r.mu.Unlock()
+	return r.Reader.ReadAt(p, off)
+}
+
+func (r *countingReaderAt) reset() [][2]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func TestBlockCache(t *testing.T) {
+	assert := assert.New(t)
+
+	data := make([]byte, 1000)
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range data {
+		data[i] = byte(rnd.Uint32())
+	}
+
+	for _, size := range []int64{int64(len(data)), -1} {
+		c := newBlockCache(bytes.NewReader(data), size, &CacheOptions{BlockSize: 100, Budget: 300, ReadAhead: 1})
+
+		for range 1000 {
+			off, n := rnd.IntN(1100), rnd.IntN(300)
+			p := make([]byte, n)
+			read, err := c.ReadAt(p, int64(off))
+
+			end := min(off+n, len(data))
+			expected := data[min(off, end):end]
+			if assert.Equal(len(expected), read, "ReadAt(%d, %d)", off, n) {
+				assert.Equal(expected, p[:read])
+			}
+			if read < n {
+				assert.Equal(io.EOF, err)
+			} else {
+				assert.NoError(err)
+			}
+		}
+
+		assert.LessOrEqual(c.lru.Len(), 3)
+	}
+}
+
+func TestBlockCacheCoalescing(t *testing.T) {
+	assert := assert.New(t)
+
+	ra := &countingReaderAt{Reader: bytes.NewReader(make([]byte, 1000))}
+	c := newBlockCache(ra, 1000, &CacheOptions{BlockSize: 100})
+
+	_, err := c.ReadAt(make([]byte, 10), 250)
+	assert.NoError(err)
+	assert.Equal([][2]int64{{200, 100}}, ra.reset())
+
+	// Blocks 1 and 3 to 5 are missing
+	_, err = c.ReadAt(make([]byte, 450), 150)
+	assert.NoError(err)
+	assert.Equal([][2]int64{{100, 100}, {300, 300}}, ra.reset())
+
+	_, err = c.ReadAt(make([]byte, 500), 100)
+	assert.NoError(err)
+	assert.Empty(ra.reset())
+}
+
+func TestBlockCacheEviction(t *testing.T) {
+	assert := assert.New(t)
+
+	ra := &countingReaderAt{Reader: bytes.NewReader(make([]byte, 1000))}
+	c := newBlockCache(ra, 1000, &CacheOptions{BlockSize: 100, Budget: 200})
+
+	for _, off := range []int64{500, 700, 500, 900} {
+		_, err := c.ReadAt(make([]byte, 10), off)
+		assert.NoError(err)
+	}
+	assert.Equal([][2]int64{{500, 100}, {700, 100}, {900, 100}}, ra.reset())
+
+	// Block 7 is the least recently used
+	_, err := c.ReadAt(make([]byte, 10), 500)
+	assert.NoError(err)
+	_, err = c.ReadAt(make([]byte, 10), 700)
+	assert.NoError(err)
+	assert.Equal([][2]int64{{700, 100}}, ra.reset())
+}
+
+func TestBlockCacheReadAhead(t *testing.T) {
+	assert := assert.New(t)
+
+	ra := &countingReaderAt{Reader: bytes.NewReader(make([]byte, 1000))}
+	c := newBlockCache(ra, 1000, &CacheOptions{BlockSize: 100, ReadAhead: 2})
+
+	for off := int64(0); off < 1000; off += 50 {
+		_, err := c.ReadAt(make([]byte, 50), off)
+		assert.NoError(err)
+	}
+	assert.Equal([][2]int64{{0, 300}, {300, 300}, {600, 300}, {900, 100}}, ra.reset())
+
+	// Random access does not read ahead
+	c = newBlockCache(ra, 1000, &CacheOptions{BlockSize: 100, ReadAhead: 2})
+	_, err := c.ReadAt(make([]byte, 50), 500)
+	assert.NoError(err)
+	assert.Equal([][2]int64{{500, 100}}, ra.reset())
+}
+
+func TestWithCache(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	data, err := os.ReadFile("test.tar")
+	require.NoError(err)
+
+	ra := &countingReaderAt{Reader: bytes.NewReader(data)}
+
+	tfs, err := New(ra, WithCache(&CacheOptions{BlockSize: 4 << 10}))
+	require.NoError(err)
+
+	err = fstest.TestFS(tfs, "bar", "foo", "dir1", "dir1/dir11", "dir1/dir11/file111", "dir1/file11", "dir1/file12", "dir2", "dir2/dir21", "dir2/dir21/file211", "dir2/dir21/file212")
+	require.NoError(err)
+
+	// Every block of the archive is read once
+	assert.LessOrEqual(len(ra.reset()), (len(data)+4<<10-1)/(4<<10))
+}
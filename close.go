@@ -0,0 +1,120 @@
+package tarfs
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// handle tracks the lifecycle of a tarfs and of the files opened from it.
+type handle struct {
+	mu     sync.Mutex
+	closed bool
+	files  map[*file]struct{}
+	close  func() error
+	// rw is held for reading by reads of the archive which must complete before close is called, see acquire
+	rw sync.RWMutex
+}
+
+func newHandle() *handle {
+	return &handle{files: make(map[*file]struct{})}
+}
+
+func (h *handle) isClosed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.closed
+}
+
+// acquire prevents the handle from being closed until release is called,
+// it returns false if the handle is already closed.
+func (h *handle) acquire() bool {
+	h.rw.RLock()
+	if h.isClosed() {
+		h.rw.RUnlock()
+		return false
+	}
+	return true
+}
+
+func (h *handle) release() {
+	h.rw.RUnlock()
+}
+
+// open registers f as opened, it returns false if the handle is already closed.
+func (h *handle) open(f *file) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	f.h = h
+	h.files[f] = struct{}{}
+
+	return true
+}
+
+// isFileClosed reports whether f has been closed, either directly or by closing the handle.
+func (h *handle) isFileClosed(f *file) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return f.closed
+}
+
+// closeFile marks f as closed, it returns false if f was already closed.
+func (h *handle) closeFile(f *file) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if f.closed {
+		return false
+	}
+
+	f.closed = true
+	delete(h.files, f)
+
+	return true
+}
+
+func (h *handle) closeAll() error {
+	// Wait for the reads in progress
+	h.rw.Lock()
+	defer h.rw.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return fs.ErrClosed
+	}
+
+	h.closed = true
+
+	for f := range h.files {
+		f.closed = true
+	}
+	h.files = nil
+
+	if h.close == nil {
+		return nil
+	}
+
+	return h.close()
+}
+
+// Close closes the fs.FS and all the files opened from it,
+// and releases the resources it holds, such as the mapping of NewFromFile
+// or the io.Reader given to New with WithOwnedReader.
+// Subsequent calls to Open, ReadFile, ReadDir, Stat and Glob return fs.ErrClosed,
+// and the Sys method of the fs.FileInfo obtained before returns nil for the headers read again from the archive.
+// The fs.FS returned by Sub share the lifecycle of their parent.
+func (tfs *tarfs) Close() error {
+	if err := tfs.h.closeAll(); err != nil {
+		return newErr("close", ".", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClose(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f)
+	require.NoError(err)
+
+	file, err := tfs.Open("foo")
+	require.NoError(err)
+
+	dir, err := tfs.Open("dir1")
+	require.NoError(err)
+
+	require.NoError(tfs.Close())
+
+	_, err = tfs.Open("bar")
+	assert.ErrorIs(err, fs.ErrClosed, "when tarfs.Open(\"bar\")")
+
+	_, err = fs.ReadFile(tfs, "bar")
+	assert.ErrorIs(err, fs.ErrClosed, "when fs.ReadFile(tfs, \"bar\")")
+
+	_, err = fs.ReadDir(tfs, "dir1")
+	assert.ErrorIs(err, fs.ErrClosed, "when fs.ReadDir(tfs, \"dir1\")")
+
+	_, err = fs.Stat(tfs, "dir1")
+	assert.ErrorIs(err, fs.ErrClosed, "when fs.Stat(tfs, \"dir1\")")
+
+	_, err = fs.Glob(tfs, "*")
+	assert.ErrorIs(err, fs.ErrClosed, "when fs.Glob(tfs, \"*\")")
+
+	_, err = file.Read(make([]byte, 1))
+	assert.ErrorIs(err, fs.ErrClosed, "when file{\"foo\"}.Read()")
+
+	_, err = dir.(fs.ReadDirFile).ReadDir(-1)
+	assert.ErrorIs(err, fs.ErrClosed, "when file{\"dir1\"}.ReadDir()")
+
+	assert.ErrorIs(file.Close(), fs.ErrClosed, "when file{\"foo\"}.Close()")
+
+	assert.ErrorIs(tfs.Close(), fs.ErrClosed, "when closing twice")
+
+	// The reader is not owned by tfs
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NoError(err)
+}
+
+func TestCloseSub(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+
+	subfs, err := fs.Sub(tfs, "dir1")
+	require.NoError(err)
+
+	require.NoError(tfs.Close())
+
+	_, err = fs.ReadFile(subfs, "file11")
+	require.ErrorIs(err, fs.ErrClosed)
+}
+
+func TestWithOwnedReader(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f, WithOwnedReader())
+	require.NoError(err)
+
+	b, err := fs.ReadFile(tfs, "foo")
+	require.NoError(err)
+	require.Equal("foo", string(b))
+
+	require.NoError(tfs.Close())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.ErrorIs(err, os.ErrClosed)
+}
+
+func TestCloseSys(t *testing.T) {
+	for name, newFS := range map[string]func() (FS, error){
+		"New": func() (FS, error) {
+			b, err := os.ReadFile("test.tar")
+			if err != nil {
+				return nil, err
+			}
+			return New(bytes.NewReader(b))
+		},
+		"NewFromFile": func() (FS, error) { return NewFromFile("test.tar") },
+		"WithOwnedReader": func() (FS, error) {
+			f, err := os.Open("test.tar")
+			if err != nil {
+				return nil, err
+			}
+			return New(f, WithOwnedReader())
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			tfs, err := newFS()
+			require.NoError(err)
+
+			info, err := fs.Stat(tfs, "foo")
+			require.NoError(err)
+			entries, err := fs.ReadDir(tfs, "dir1")
+			require.NoError(err)
+			require.NotEmpty(entries)
+
+			require.IsType(&tar.Header{}, info.Sys())
+
+			require.NoError(tfs.Close())
+
+			assert.Nil(info.Sys())
+			assert.Equal("foo", info.Name())
+			assert.Equal(int64(3), info.Size())
+
+			entryInfo, err := entries[0].Info()
+			require.NoError(err)
+			assert.Nil(entryInfo.Sys())
+			assert.Equal(entries[0].Name(), entryInfo.Name())
+		})
+	}
+}
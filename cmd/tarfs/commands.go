@@ -0,0 +1,410 @@
+package main
+
+import (
+	"archive/tar"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/nlepage/go-tarfs"
+)
+
+func ls(fsys fs.FS, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	long := flags.Bool("l", false, "long listing, in the format of tar -tv")
+	recursive := flags.Bool("R", false, "list directories recursively")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	l := newLister(stdout, *long)
+
+	for _, p := range paths(flags.Args()) {
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			if err := l.print(p, info); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if *recursive {
+			err := fs.WalkDir(fsys, p, func(name string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if name == p {
+					return nil
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				return l.print(name, info)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		entries, err := fs.ReadDir(fsys, p)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			if err := l.print(path.Join(p, e.Name()), info); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func cat(fsys fs.FS, args []string, stdout io.Writer) error {
+	for _, p := range args {
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(stdout, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stat(fsys fs.FS, args []string, stdout io.Writer) error {
+	for _, p := range args {
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "  File: %s\n", p)
+		fmt.Fprintf(stdout, "  Size: %d\n", info.Size())
+		fmt.Fprintf(stdout, "  Mode: %04o (%s)\n", info.Mode().Perm(), modeString(info))
+		fmt.Fprintf(stdout, "Modify: %s\n", info.ModTime().Local().Format(time.RFC3339))
+
+		if h, ok := info.Sys().(*tar.Header); ok {
+			fmt.Fprintf(stdout, " Owner: %d (%s)\n", h.Uid, h.Uname)
+			fmt.Fprintf(stdout, " Group: %d (%s)\n", h.Gid, h.Gname)
+			if h.Linkname != "" {
+				fmt.Fprintf(stdout, "  Link: %s\n", h.Linkname)
+			}
+		}
+	}
+
+	return nil
+}
+
+func tree(fsys fs.FS, args []string, stdout io.Writer) error {
+	var dirs, files int
+
+	var walk func(name, indent string) error
+	walk = func(name, indent string) error {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		for i, e := range entries {
+			branch, next := "├── ", "│   "
+			if i == len(entries)-1 {
+				branch, next = "└── ", "    "
+			}
+
+			fmt.Fprintf(stdout, "%s%s%s\n", indent, branch, e.Name())
+
+			if !e.IsDir() {
+				files++
+				continue
+			}
+
+			dirs++
+			if err := walk(path.Join(name, e.Name()), indent+next); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, p := range paths(args) {
+		fmt.Fprintln(stdout, p)
+		if err := walk(p, ""); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(stdout, "\n%d directories, %d files\n", dirs, files)
+	return err
+}
+
+func find(fsys fs.FS, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("find", flag.ContinueOnError)
+	name := flags.String("name", "", "base name `pattern`")
+	size := flags.String("size", "", "size `[+-]n[kMG]`, greater than n with +, less than n with -")
+	typ := flags.String("type", "", "file `type`: f for regular files, d for directories, l for symbolic links")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var matchers []func(p string, info fs.FileInfo) bool
+
+	if *name != "" {
+		if _, err := path.Match(*name, ""); err != nil {
+			return err
+		}
+		matchers = append(matchers, func(p string, _ fs.FileInfo) bool {
+			match, _ := path.Match(*name, path.Base(p))
+			return match
+		})
+	}
+
+	if *size != "" {
+		matchSize, err := parseSize(*size)
+		if err != nil {
+			return err
+		}
+		matchers = append(matchers, func(_ string, info fs.FileInfo) bool {
+			return matchSize(info.Size())
+		})
+	}
+
+	if *typ != "" {
+		var mask fs.FileMode
+		switch *typ {
+		case "f":
+		case "d":
+			mask = fs.ModeDir
+		case "l":
+			mask = fs.ModeSymlink
+		default:
+			return fmt.Errorf("find: unknown type %q", *typ)
+		}
+		matchers = append(matchers, func(_ string, info fs.FileInfo) bool {
+			return info.Mode().Type() == mask
+		})
+	}
+
+	for _, p := range paths(flags.Args()) {
+		err := fs.WalkDir(fsys, p, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			for _, match := range matchers {
+				if !match(name, info) {
+					return nil
+				}
+			}
+
+			_, err = fmt.Fprintln(stdout, name)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseSize parses the argument of find -size.
+func parseSize(s string) (func(int64) bool, error) {
+	cmp := byte(0)
+	if s[0] == '+' || s[0] == '-' {
+		cmp, s = s[0], s[1:]
+	}
+
+	unit := int64(1)
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'c':
+			s = s[:len(s)-1]
+		case 'k':
+			unit, s = 1<<10, s[:len(s)-1]
+		case 'M':
+			unit, s = 1<<20, s[:len(s)-1]
+		case 'G':
+			unit, s = 1<<30, s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("find: invalid size: %w", err)
+	}
+	n *= unit
+
+	switch cmp {
+	case '+':
+		return func(size int64) bool { return size > n }, nil
+	case '-':
+		return func(size int64) bool { return size < n }, nil
+	default:
+		return func(size int64) bool { return size == n }, nil
+	}
+}
+
+func du(fsys fs.FS, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("du", flag.ContinueOnError)
+	summarize := flags.Bool("s", false, "print only the total size of each argument")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var walk func(name string) (int64, error)
+	walk = func(name string) (int64, error) {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return 0, err
+		}
+
+		var total int64
+		for _, e := range entries {
+			if e.IsDir() {
+				size, err := walk(path.Join(name, e.Name()))
+				if err != nil {
+					return 0, err
+				}
+				total += size
+				continue
+			}
+
+			info, err := e.Info()
+			if err != nil {
+				return 0, err
+			}
+			total += info.Size()
+		}
+
+		if !*summarize {
+			if _, err := fmt.Fprintf(stdout, "%d\t%s\n", total, name); err != nil {
+				return 0, err
+			}
+		}
+
+		return total, nil
+	}
+
+	for _, p := range paths(flags.Args()) {
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			fmt.Fprintf(stdout, "%d\t%s\n", info.Size(), p)
+			continue
+		}
+
+		total, err := walk(p)
+		if err != nil {
+			return err
+		}
+
+		if *summarize {
+			fmt.Fprintf(stdout, "%d\t%s\n", total, p)
+		}
+	}
+
+	return nil
+}
+
+func extract(fsys fs.FS, args []string, _ io.Writer) error {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	dir := flags.String("C", ".", "extract to `directory`")
+	sameOwner := flags.Bool("same-owner", false, "preserve the owner of files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	opts := &tarfs.ExtractOptions{PreserveOwner: *sameOwner}
+
+	for _, p := range paths(flags.Args()) {
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		var sub fs.FS
+		dest := filepath.Join(*dir, filepath.FromSlash(p))
+
+		if info.IsDir() {
+			if sub, err = fs.Sub(fsys, p); err != nil {
+				return err
+			}
+		} else {
+			sub = fileFS{fsys, p}
+			dest = filepath.Dir(dest)
+		}
+
+		if err := tarfs.Extract(sub, dest, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fileFS is an fs.FS containing only the file name of fsys.
+type fileFS struct {
+	fsys fs.FS
+	name string
+}
+
+func (f fileFS) Open(name string) (fs.File, error) {
+	if name != path.Base(f.name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.fsys.Open(f.name)
+}
+
+func (f fileFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	info, err := fs.Stat(f.fsys, f.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return []fs.DirEntry{fs.FileInfoToDirEntry(info)}, nil
+}
+
+func (f fileFS) Stat(name string) (fs.FileInfo, error) {
+	switch name {
+	case ".":
+		return fs.Stat(f.fsys, path.Dir(f.name))
+	case path.Base(f.name):
+		return fs.Stat(f.fsys, f.name)
+	default:
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+}
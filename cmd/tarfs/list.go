@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+
+	"github.com/nlepage/go-tarfs"
+)
+
+// lister prints files in the format of tar -t, or tar -tv if long is set.
+type lister struct {
+	w    io.Writer
+	long bool
+	// ugswidth is the width of the user, group and size columns,
+	// which grows with the longest ones printed, like GNU tar does.
+	ugswidth int
+}
+
+func newLister(w io.Writer, long bool) *lister {
+	return &lister{w: w, long: long, ugswidth: 19}
+}
+
+func (l *lister) print(name string, info fs.FileInfo) error {
+	if info.IsDir() && name != "." {
+		name += "/"
+	}
+
+	if !l.long {
+		_, err := fmt.Fprintln(l.w, name)
+		return err
+	}
+
+	h, _ := info.Sys().(*tar.Header)
+
+	user, group := "0", "0"
+	if h != nil {
+		user, group = h.Uname, h.Gname
+		if user == "" {
+			user = strconv.Itoa(h.Uid)
+		}
+		if group == "" {
+			group = strconv.Itoa(h.Gid)
+		}
+	}
+
+	size := strconv.FormatInt(info.Size(), 10)
+	if major, minor, ok := tarfs.Device(info); ok {
+		size = strconv.FormatInt(major, 10) + "," + strconv.FormatInt(minor, 10)
+	}
+
+	pad := len(user) + 1 + len(group) + 1 + len(size)
+	if pad > l.ugswidth {
+		l.ugswidth = pad
+	}
+
+	mtime := info.ModTime().Local().Format("2006-01-02 15:04")
+
+	link := ""
+	if h != nil {
+		switch h.Typeflag {
+		case tar.TypeSymlink:
+			link = " -> " + h.Linkname
+		case tar.TypeLink:
+			link = " link to " + h.Linkname
+		}
+	}
+
+	_, err := fmt.Fprintf(l.w, "%s %s/%s %*s %s %s%s\n", modeString(info), user, group, l.ugswidth-pad+len(size), size, mtime, name, link)
+	return err
+}
+
+// modeString returns the mode of info, as printed by tar -tv.
+func modeString(info fs.FileInfo) string {
+	mode := info.Mode()
+
+	b := []byte("----------")
+
+	b[0] = typeChar(info)
+
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+
+	special := func(i int, set bool, c byte) {
+		if !set {
+			return
+		}
+		if b[i] == 'x' {
+			b[i] = c
+		} else {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	special(3, mode&fs.ModeSetuid != 0, 's')
+	special(6, mode&fs.ModeSetgid != 0, 's')
+	special(9, mode&fs.ModeSticky != 0, 't')
+
+	return string(b)
+}
+
+func typeChar(info fs.FileInfo) byte {
+	if h, ok := info.Sys().(*tar.Header); ok && !info.IsDir() {
+		switch h.Typeflag {
+		case tar.TypeLink:
+			return 'h'
+		case tar.TypeSymlink:
+			return 'l'
+		case tar.TypeChar:
+			return 'c'
+		case tar.TypeBlock:
+			return 'b'
+		case tar.TypeFifo:
+			return 'p'
+		case tar.TypeCont:
+			return 'C'
+		}
+	}
+
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		return 'd'
+	case mode&fs.ModeSymlink != 0:
+		return 'l'
+	case mode&fs.ModeCharDevice != 0:
+		return 'c'
+	case mode&fs.ModeDevice != 0:
+		return 'b'
+	case mode&fs.ModeNamedPipe != 0:
+		return 'p'
+	case mode&fs.ModeSocket != 0:
+		return 's'
+	default:
+		return '-'
+	}
+}
@@ -0,0 +1,147 @@
+// Command tarfs inspects the contents of a tar archive.
+//
+// Usage:
+//
+//	tarfs [-f archive] [-i] command [flags] [args]
+//
+// The archive is read from the standard input if -f is not given,
+// and may be gzip compressed.
+// With -i, zero blocks are ignored to read concatenated archives, like tar --ignore-zeros.
+//
+// The commands are:
+//
+//	ls [-l] [-R] [path...]       list files, in the format of tar -t (or tar -tv with -l)
+//	cat path...                  print the content of files
+//	stat path...                 print the information of files
+//	tree [path...]               print the tree of files
+//	find [-name pattern] [-size [+-]n[kMG]] [-type f|d|l] [path...]
+//	                             print the files matching all the given criteria
+//	du [-s] [path...]            print the size of directories
+//	extract [-C dir] [-same-owner] [path...]
+//	                             extract files to a directory
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/nlepage/go-tarfs"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "tarfs:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: tarfs [-f archive] [-i] ls|cat|stat|tree|find|du|extract [flags] [args]")
+
+type command func(fsys fs.FS, args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"ls":      ls,
+	"cat":     cat,
+	"stat":    stat,
+	"tree":    tree,
+	"find":    find,
+	"du":      du,
+	"extract": extract,
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("tarfs", flag.ContinueOnError)
+	archive := flags.String("f", "-", "archive `file`, - for the standard input")
+	ignoreZeros := flags.Bool("i", false, "ignore zero blocks, to read concatenated archives")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%w", flags.Arg(0), errUsage)
+	}
+
+	var opts []tarfs.Option
+	if *ignoreZeros {
+		opts = append(opts, tarfs.WithIgnoreZeros())
+	}
+
+	tfs, err := open(*archive, stdin, opts...)
+	if err != nil {
+		return err
+	}
+	defer tfs.Close()
+
+	w := bufio.NewWriter(stdout)
+
+	if err := cmd(tfs, flags.Args()[1:], w); err != nil {
+		w.Flush()
+		return err
+	}
+
+	return w.Flush()
+}
+
+// open opens the archive named name, or stdin if name is "-".
+func open(name string, stdin io.Reader, opts ...tarfs.Option) (tarfs.FS, error) {
+	if name == "-" {
+		br := bufio.NewReader(stdin)
+		if isGzip(br) {
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			return tarfs.New(zr, opts...)
+		}
+		return tarfs.New(br, opts...)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isGzip(bufio.NewReader(f)) {
+		f.Close()
+		return tarfs.NewFromFile(name, opts...)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	defer f.Close()
+
+	return tarfs.New(zr, opts...)
+}
+
+func isGzip(br *bufio.Reader) bool {
+	magic, _ := br.Peek(2)
+	return bytes.Equal(magic, []byte{0x1f, 0x8b})
+}
+
+// paths returns args, or "." if args is empty.
+func paths(args []string) []string {
+	if len(args) == 0 {
+		return []string{"."}
+	}
+	return args
+}
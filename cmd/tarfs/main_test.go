@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTar = "../../test.tar"
+
+func TestLsLong(t *testing.T) {
+	require := require.New(t)
+
+	time.Local = time.UTC
+
+	var stdout bytes.Buffer
+	require.NoError(run([]string{"-f", testTar, "ls", "-l", "dir1"}, nil, &stdout))
+
+	require.Equal(strings.Join([]string{
+		"drwxr-xr-x nico/nico         0 2021-02-03 23:22 dir1/dir11/",
+		"-rw-r--r-- nico/nico         6 2021-02-03 23:17 dir1/file11",
+		"-rw-r--r-- nico/nico         6 2021-02-03 23:22 dir1/file12",
+		"",
+	}, "\n"), stdout.String())
+}
+
+func TestLsRecursive(t *testing.T) {
+	require := require.New(t)
+
+	var stdout bytes.Buffer
+	require.NoError(run([]string{"-f", testTar, "ls", "-R", "dir2"}, nil, &stdout))
+
+	require.Equal("dir2/dir21/\ndir2/dir21/file211\ndir2/dir21/file212\n", stdout.String())
+}
+
+func TestCatStdinGzip(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile(testTar)
+	require.NoError(err)
+
+	var stdin bytes.Buffer
+	zw := gzip.NewWriter(&stdin)
+	_, err = zw.Write(b)
+	require.NoError(err)
+	require.NoError(zw.Close())
+
+	var stdout bytes.Buffer
+	require.NoError(run([]string{"cat", "foo", "dir1/file11"}, &stdin, &stdout))
+
+	require.Equal("foofile11", stdout.String())
+}
+
+func TestIgnoreZeros(t *testing.T) {
+	require := require.New(t)
+
+	b, err := os.ReadFile(testTar)
+	require.NoError(err)
+
+	sparse, err := os.ReadFile("../../test-sparse.tar")
+	require.NoError(err)
+
+	concat := append(b, sparse...)
+
+	var stdout bytes.Buffer
+	require.Error(run([]string{"cat", "file2"}, bytes.NewReader(concat), &stdout))
+
+	stdout.Reset()
+	require.NoError(run([]string{"-i", "cat", "foo", "file2"}, bytes.NewReader(concat), &stdout))
+	require.Equal("foofile2", stdout.String())
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-name", "file1*"}, "dir1/dir11/file111\ndir1/file11\ndir1/file12\n"},
+		{[]string{"-type", "d", "dir2"}, "dir2\ndir2/dir21\n"},
+		{[]string{"-type", "f", "-size", "+6c"}, "dir1/dir11/file111\ndir2/dir21/file211\ndir2/dir21/file212\n"},
+		{[]string{"-size", "-4"}, ".\nbar\ndir1\ndir1/dir11\ndir2\ndir2/dir21\nfoo\n"},
+	} {
+		var stdout bytes.Buffer
+		if assert.NoErrorf(run(append([]string{"-f", testTar, "find"}, test.args...), nil, &stdout), "find %v", test.args) {
+			assert.Equalf(test.expected, stdout.String(), "find %v", test.args)
+		}
+	}
+}
+
+func TestDu(t *testing.T) {
+	require := require.New(t)
+
+	var stdout bytes.Buffer
+	require.NoError(run([]string{"-f", testTar, "du", "-s", ".", "dir1"}, nil, &stdout))
+
+	require.Equal("39\t.\n19\tdir1\n", stdout.String())
+}
+
+func TestExtract(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+
+	require.NoError(run([]string{"-f", testTar, "extract", "-C", dir, "dir1/dir11", "foo"}, nil, &bytes.Buffer{}))
+
+	b, err := os.ReadFile(filepath.Join(dir, "dir1", "dir11", "file111"))
+	require.NoError(err)
+	require.Equal("file111", string(b))
+
+	b, err = os.ReadFile(filepath.Join(dir, "foo"))
+	require.NoError(err)
+	require.Equal("foo", string(b))
+
+	_, err = os.Stat(filepath.Join(dir, "bar"))
+	require.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	require.ErrorIs(t, run([]string{"-f", testTar, "rm"}, nil, &bytes.Buffer{}), errUsage)
+}
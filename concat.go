@@ -0,0 +1,80 @@
+package tarfs
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var zeroBlock [blockSize]byte
+
+// newReadCounter returns a readCounterIface reading ra from off.
+func newReadCounter(ra readReaderAt, off int64) (readCounterIface, error) {
+	if rs, isReadSeeker := ra.(io.ReadSeeker); isReadSeeker {
+		cr := &readSeekCounter{ReadSeeker: rs}
+		if off != 0 {
+			if _, err := cr.Seek(off, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		return cr, nil
+	}
+
+	return &readCounter{Reader: io.NewSectionReader(ra, off, 1<<63-1-off), off: off}, nil
+}
+
+// nextMember returns the offset of the first block which is not a zero block, starting at off,
+// which is the start of the next member of concatenated archives.
+// It returns -1 if there are only zero blocks until the end of ra.
+func nextMember(ra io.ReaderAt, off int64) (int64, error) {
+	var blk [blockSize]byte
+	for ; ; off += blockSize {
+		if _, err := ra.ReadAt(blk[:], off); err != nil {
+			if err == io.EOF {
+				return -1, nil
+			}
+			return -1, err
+		}
+		if blk != zeroBlock {
+			return off, nil
+		}
+	}
+}
+
+// isLoneZeroBlock reports whether the tar.ErrHeader returned by tar.Reader.Next at off
+// is caused by a single zero block followed by a valid header, which ends the archive
+// unless zero blocks are ignored.
+func isLoneZeroBlock(ra io.ReaderAt, off int64) bool {
+	if off < 2*blockSize {
+		return false
+	}
+
+	var blks [2 * blockSize]byte
+	if _, err := ra.ReadAt(blks[:], off-2*blockSize); err != nil {
+		return false
+	}
+
+	return bytes.Equal(blks[:blockSize], zeroBlock[:]) && isHeader(blks[blockSize:])
+}
+
+// isHeader reports whether the checksum of the header block blk is valid.
+func isHeader(blk []byte) bool {
+	chksum, err := strconv.ParseInt(strings.Trim(string(blk[148:156]), " \x00"), 8, 64)
+	if err != nil {
+		return false
+	}
+
+	// The checksum is computed with the checksum field filled with spaces,
+	// either with unsigned or, by some old implementations, signed bytes.
+	var unsigned, signed int64
+	for i, b := range blk[:blockSize] {
+		if 148 <= i && i < 156 {
+			b = ' '
+		}
+		unsigned += int64(b)
+		signed += int64(int8(b))
+	}
+
+	return chksum == unsigned || chksum == signed
+}
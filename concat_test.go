@@ -0,0 +1,116 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readerAt hides the io.Seeker implementation of a reader.
+type readerAt struct {
+	io.Reader
+	io.ReaderAt
+}
+
+func TestWithIgnoreZeros(t *testing.T) {
+	testTar, err := os.ReadFile("test.tar")
+	require.NoError(t, err)
+
+	sparseTar, err := os.ReadFile("test-sparse.tar")
+	require.NoError(t, err)
+
+	day := newTestArchive(t,
+		testEntry{&tar.Header{Name: "logs/day3.log", Typeflag: tar.TypeReg, Mode: 0644}, "day3"},
+	)
+
+	// A lone zero block between members
+	lone := newTestArchive(t,
+		testEntry{&tar.Header{Name: "logs/day4.log", Typeflag: tar.TypeReg, Mode: 0644}, "day4"},
+	)
+	lone = lone[:len(lone)-blockSize]
+
+	var archive []byte
+	archive = append(archive, testTar...)
+	archive = append(archive, sparseTar...)
+	archive = append(archive, day...)
+	archive = append(archive, make([]byte, 10*blockSize)...) // record padding
+	archive = append(archive, lone...)
+	archive = append(archive, newTestArchive(t,
+		testEntry{&tar.Header{Name: "logs/day5.log", Typeflag: tar.TypeReg, Mode: 0644}, "day5"},
+	)...)
+
+	name := filepath.Join(t.TempDir(), "concat.tar")
+	require.NoError(t, os.WriteFile(name, archive, 0644))
+
+	for _, tc := range []struct {
+		name  string
+		newFS func(opts ...Option) (FS, error)
+	}{
+		{"bytes.Reader", func(opts ...Option) (FS, error) { return New(bytes.NewReader(archive), opts...) }},
+		{"io.ReaderAt", func(opts ...Option) (FS, error) {
+			r := bytes.NewReader(archive)
+			return New(readerAt{r, r}, opts...)
+		}},
+		{"os.File", func(opts ...Option) (FS, error) {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			return New(f, append(opts, WithOwnedReader())...)
+		}},
+		{"NewFromFile", func(opts ...Option) (FS, error) { return NewFromFile(name, opts...) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			tfs, err := tc.newFS()
+			require.NoError(err)
+			defer tfs.Close()
+
+			_, err = fs.Stat(tfs, "file1")
+			assert.ErrorIs(err, fs.ErrNotExist, "without WithIgnoreZeros")
+
+			tfs, err = tc.newFS(WithIgnoreZeros())
+			require.NoError(err)
+			defer tfs.Close()
+
+			require.NoError(fstest.TestFS(tfs, "foo", "dir1/dir11/file111", "file1", "file2", "logs/day3.log", "logs/day4.log", "logs/day5.log"))
+
+			for name, content := range map[string]string{
+				"foo":           "foo",
+				"file2":         "file2",
+				"logs/day3.log": "day3",
+				"logs/day4.log": "day4",
+				"logs/day5.log": "day5",
+			} {
+				b, err := fs.ReadFile(tfs, name)
+				if assert.NoError(err, name) {
+					assert.Equal(content, string(b), name)
+				}
+			}
+
+			file1, err := fs.ReadFile(tfs, "file1")
+			require.NoError(err)
+			assert.Len(file1, 1000000)
+			assert.Equal([]byte{1, 1}, file1[999998:])
+		})
+	}
+}
+
+func TestWithIgnoreZerosInvalidHeader(t *testing.T) {
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0644}, "foo"},
+	)
+	archive = append(archive, bytes.Repeat([]byte{0xff}, blockSize)...)
+
+	_, err := New(bytes.NewReader(archive), WithIgnoreZeros())
+	require.ErrorIs(t, err, tar.ErrHeader)
+}
@@ -0,0 +1,267 @@
+package tarfs
+
+import (
+	"bytes"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	// Content compares the content of regular files, by hashing them.
+	// Only files which are the same data of the same archive, such as in two Sub of a tar fs.FS, are not hashed:
+	// files of distinct archives are hashed even if their headers and data offsets are identical,
+	// because tar headers do not describe the content.
+	Content bool
+}
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+// Kinds of Change
+const (
+	Added ChangeKind = iota + 1
+	Removed
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Fields is a set of fields of a file.
+type Fields uint
+
+// Fields of a file
+const (
+	FieldType Fields = 1 << iota
+	FieldMode
+	FieldSize
+	FieldModTime
+	FieldUid
+	FieldGid
+	FieldLinkname
+	FieldContent
+	FieldDevice
+)
+
+var fieldNames = []string{"type", "mode", "size", "mtime", "uid", "gid", "linkname", "content", "device"}
+
+func (f Fields) String() string {
+	var names []string
+	for i, name := range fieldNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Change is a file added, removed or modified between two fs.FS.
+type Change struct {
+	Name string
+	Kind ChangeKind
+	// Fields which differ, for modified files
+	Fields Fields
+}
+
+// Diff returns the changes between the files of a and b, sorted by name.
+// Uid, gid, link name and device numbers are compared only if both files have a tar header.
+// With DiffOptions.Content, the content of regular files having the same size is compared by hashing it,
+// unless both files are the same data of the same archive, see DiffOptions.Content.
+func Diff(a, b fs.FS, opts *DiffOptions) ([]Change, error) {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+
+	aEntries, err := walkEntries(a)
+	if err != nil {
+		return nil, err
+	}
+
+	bEntries, err := walkEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(aEntries))
+	for name := range aEntries {
+		names = append(names, name)
+	}
+	for name := range bEntries {
+		if _, ok := aEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+
+	for _, name := range names {
+		aEntry, inA := aEntries[name]
+		bEntry, inB := bEntries[name]
+
+		switch {
+		case !inA:
+			changes = append(changes, Change{Name: name, Kind: Added})
+		case !inB:
+			changes = append(changes, Change{Name: name, Kind: Removed})
+		default:
+			fields, err := diffFile(a, b, name, aEntry, bEntry, opts)
+			if err != nil {
+				return nil, err
+			}
+			if fields != 0 {
+				changes = append(changes, Change{Name: name, Kind: Modified, Fields: fields})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// walkEntries returns the entries of fsys by name.
+func walkEntries(fsys fs.FS) (map[string]fs.DirEntry, error) {
+	entries := make(map[string]fs.DirEntry)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." {
+			entries[name] = d
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// diffFile returns the fields which differ between the file name of a and b.
+func diffFile(a, b fs.FS, name string, aEntry, bEntry fs.DirEntry, opts *DiffOptions) (Fields, error) {
+	aInfo, err := aEntry.Info()
+	if err != nil {
+		return 0, err
+	}
+
+	bInfo, err := bEntry.Info()
+	if err != nil {
+		return 0, err
+	}
+
+	var fields Fields
+
+	if aInfo.Mode().Type() != bInfo.Mode().Type() {
+		fields |= FieldType
+	}
+	if aInfo.Mode()&^fs.ModeType != bInfo.Mode()&^fs.ModeType {
+		fields |= FieldMode
+	}
+	if !aInfo.ModTime().Equal(bInfo.ModTime()) {
+		fields |= FieldModTime
+	}
+
+	aHeader, err := fileHeader(aInfo)
+	if err != nil {
+		return 0, err
+	}
+	bHeader, err := fileHeader(bInfo)
+	if err != nil {
+		return 0, err
+	}
+
+	if aHeader != nil && bHeader != nil {
+		if aHeader.Uid != bHeader.Uid {
+			fields |= FieldUid
+		}
+		if aHeader.Gid != bHeader.Gid {
+			fields |= FieldGid
+		}
+		if aHeader.Linkname != bHeader.Linkname {
+			fields |= FieldLinkname
+		}
+	}
+
+	aMajor, aMinor, aIsDevice := headerDevice(aHeader)
+	bMajor, bMinor, bIsDevice := headerDevice(bHeader)
+	if aIsDevice && bIsDevice && (aMajor != bMajor || aMinor != bMinor) {
+		fields |= FieldDevice
+	}
+
+	if !aInfo.Mode().IsRegular() || !bInfo.Mode().IsRegular() {
+		return fields, nil
+	}
+
+	if aInfo.Size() != bInfo.Size() {
+		return fields | FieldSize | FieldContent, nil
+	}
+
+	if !opts.Content {
+		return fields, nil
+	}
+
+	same, err := sameData(a, b, name)
+	if err != nil || same {
+		return fields, err
+	}
+
+	aSum, err := checksum(a, name)
+	if err != nil {
+		return 0, err
+	}
+
+	bSum, err := checksum(b, name)
+	if err != nil {
+		return 0, err
+	}
+
+	if !bytes.Equal(aSum, bSum) {
+		fields |= FieldContent
+	}
+
+	return fields, nil
+}
+
+// sameData reports whether the file name of a and b is the same data of the same archive.
+func sameData(a, b fs.FS, name string) (bool, error) {
+	aFile, err := a.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer aFile.Close()
+
+	bFile, err := b.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer bFile.Close()
+
+	aTarFile, aIsTarFile := aFile.(*file)
+	bTarFile, bIsTarFile := bFile.(*file)
+	if !aIsTarFile || !bIsTarFile {
+		return false, nil
+	}
+
+	aEntry, aIsReg := aTarFile.entry.(*regEntry)
+	bEntry, bIsReg := bTarFile.entry.(*regEntry)
+	if !aIsReg || !bIsReg || aEntry.dataOffset < 0 || aEntry.dataOffset != bEntry.dataOffset {
+		return false, nil
+	}
+
+	if !reflect.TypeOf(aEntry.ra).Comparable() || !reflect.TypeOf(bEntry.ra).Comparable() {
+		return false, nil
+	}
+
+	return aEntry.ra == bEntry.ra, nil
+}
@@ -0,0 +1,100 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	mtime := time.Date(2023, 8, 21, 10, 0, 0, 0, time.UTC)
+
+	newFS := func(entries ...testEntry) fs.FS {
+		tfs, err := New(bytes.NewReader(newTestArchive(t, entries...)))
+		require.NoError(err)
+		return tfs
+	}
+
+	v1 := newFS(
+		testEntry{&tar.Header{Name: "mode", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "mode"},
+		testEntry{&tar.Header{Name: "content", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "content"},
+		testEntry{&tar.Header{Name: "size", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "size"},
+		testEntry{&tar.Header{Name: "same", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "same"},
+		testEntry{&tar.Header{Name: "owner", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime, Uid: 1000, Gid: 1000}, ""},
+		testEntry{&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "mode", Mode: 0777, ModTime: mtime}, ""},
+		testEntry{&tar.Header{Name: "dir/removed", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "removed"},
+		testEntry{&tar.Header{Name: "dev", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3, Mode: 0666, ModTime: mtime}, ""},
+		testEntry{&tar.Header{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644, ModTime: mtime}, ""},
+	)
+
+	v2 := newFS(
+		testEntry{&tar.Header{Name: "mode", Typeflag: tar.TypeReg, Mode: 0600, ModTime: mtime.Add(time.Hour)}, "mode"},
+		testEntry{&tar.Header{Name: "content", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "CONTENT"},
+		testEntry{&tar.Header{Name: "size", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "bigger size"},
+		testEntry{&tar.Header{Name: "same", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "same"},
+		testEntry{&tar.Header{Name: "owner", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime, Uid: 1001, Gid: 1000}, ""},
+		testEntry{&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "size", Mode: 0777, ModTime: mtime}, ""},
+		testEntry{&tar.Header{Name: "dir/added", Typeflag: tar.TypeReg, Mode: 0644, ModTime: mtime}, "added"},
+		testEntry{&tar.Header{Name: "dev", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 5, Mode: 0666, ModTime: mtime}, ""},
+		testEntry{&tar.Header{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644, ModTime: mtime}, ""},
+	)
+
+	changes, err := Diff(v1, v2, nil)
+	require.NoError(err)
+	assert.Equal([]Change{
+		{Name: "dev", Kind: Modified, Fields: FieldDevice},
+		{Name: "dir/added", Kind: Added},
+		{Name: "dir/removed", Kind: Removed},
+		{Name: "link", Kind: Modified, Fields: FieldLinkname},
+		{Name: "mode", Kind: Modified, Fields: FieldMode | FieldModTime},
+		{Name: "owner", Kind: Modified, Fields: FieldUid},
+		{Name: "size", Kind: Modified, Fields: FieldSize | FieldContent},
+	}, changes)
+
+	// "content" has identical headers at the same offset in both archives, it is hashed anyway
+	changes, err = Diff(v1, v2, &DiffOptions{Content: true})
+	require.NoError(err)
+	assert.Contains(changes, Change{Name: "content", Kind: Modified, Fields: FieldContent})
+	assert.Len(changes, 8)
+}
+
+func TestDiffSameArchive(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	changes, err := Diff(tfs, tfs, &DiffOptions{Content: true})
+	require.NoError(err)
+	require.Empty(changes)
+
+	dir1, err := fs.Sub(tfs, "dir1")
+	require.NoError(err)
+
+	dir2, err := fs.Sub(tfs, "dir2")
+	require.NoError(err)
+
+	changes, err = Diff(dir1, dir2, nil)
+	require.NoError(err)
+	require.Equal([]Change{
+		{Name: "dir11", Kind: Removed},
+		{Name: "dir11/file111", Kind: Removed},
+		{Name: "dir21", Kind: Added},
+		{Name: "dir21/file211", Kind: Added},
+		{Name: "dir21/file212", Kind: Added},
+		{Name: "file11", Kind: Removed},
+		{Name: "file12", Kind: Removed},
+	}, changes)
+}
+
+func TestFieldsString(t *testing.T) {
+	assert.Equal(t, "mode|mtime|content", (FieldMode | FieldModTime | FieldContent).String())
+}
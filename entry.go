@@ -5,8 +5,6 @@ import (
 	"bytes"
 	"io"
 	"io/fs"
-	"sort"
-	"time"
 )
 
 type entry interface {
@@ -15,7 +13,7 @@ type entry interface {
 	readdir(path string) ([]fs.DirEntry, error)
 	readfile(path string) ([]byte, error)
 	entries(op, path string) ([]fs.DirEntry, error)
-	open() (fs.File, error)
+	open(path string) (*file, error)
 }
 
 type regEntry struct {
@@ -23,6 +21,10 @@ type regEntry struct {
 	name   string
 	ra     io.ReaderAt
 	offset int64
+	// dataOffset is the offset of the content in ra, or -1 if it is not contiguous
+	dataOffset int64
+	// truncated is set if the content extends past the end of ra, see WithRecovery
+	truncated bool
 }
 
 var _ entry = &regEntry{}
@@ -37,6 +39,14 @@ func (e *regEntry) readdir(path string) ([]fs.DirEntry, error) {
 }
 
 func (e *regEntry) readfile(path string) ([]byte, error) {
+	if _, isMem := e.ra.(*memReader); isMem && e.dataOffset >= 0 && !e.truncated {
+		b := make([]byte, e.size())
+		if _, err := e.ra.ReadAt(b, e.dataOffset); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
 	r, err := e.reader()
 	if err != nil {
 		return nil, err
@@ -55,16 +65,20 @@ func (e *regEntry) entries(op, path string) ([]fs.DirEntry, error) {
 	return nil, newErrNotDir(op, path)
 }
 
-func (e *regEntry) open() (fs.File, error) {
+func (e *regEntry) open(path string) (*file, error) {
 	r, err := e.reader()
 	if err != nil {
 		return nil, err
 	}
 
-	return &file{e, &readSeeker{&readCounter{r, 0}, e}, -1, false}, nil
+	return &file{entry: e, r: &readSeeker{&readCounter{r, 0}, e}, readDirPos: -1}, nil
 }
 
 func (e *regEntry) reader() (io.Reader, error) {
+	if e.dataOffset >= 0 {
+		return e.contentReader(0), nil
+	}
+
 	tr := tar.NewReader(io.NewSectionReader(e.ra, e.offset, 1<<63-1-e.offset))
 
 	if _, err := tr.Next(); err != nil {
@@ -74,18 +88,30 @@ func (e *regEntry) reader() (io.Reader, error) {
 	return tr, nil
 }
 
-type dirEntry struct {
-	fs.DirEntry
-	_entries []fs.DirEntry
-	sorted   bool
+// contentReader returns a reader of the content of e from off, which must be contiguous.
+func (e *regEntry) contentReader(off int64) io.Reader {
+	n := max(e.size()-off, 0)
+	r := io.NewSectionReader(e.ra, e.dataOffset+off, n)
+	if e.truncated {
+		return &truncatedReader{r, n}
+	}
+	return r
 }
 
-func newDirEntry(e fs.DirEntry) *dirEntry {
-	return &dirEntry{e, make([]fs.DirEntry, 0, 10), false}
+// bytes returns the content of the entry without copying it,
+// if the archive is held in memory.
+func (e *regEntry) bytes() ([]byte, bool) {
+	mr, ok := e.ra.(*memReader)
+	if !ok || e.dataOffset < 0 {
+		return nil, false
+	}
+	return mr.slice(e.dataOffset, e.size())
 }
 
-func (e *dirEntry) append(c fs.DirEntry) {
-	e._entries = append(e._entries, c)
+type dirEntry struct {
+	fs.DirEntry
+	idx *index
+	id  uint32
 }
 
 var _ entry = &dirEntry{}
@@ -95,15 +121,7 @@ func (e *dirEntry) size() int64 {
 }
 
 func (e *dirEntry) readdir(path string) ([]fs.DirEntry, error) {
-	if !e.sorted {
-		sort.Sort(entriesByName(e._entries))
-	}
-
-	entries := make([]fs.DirEntry, len(e._entries))
-
-	copy(entries, e._entries)
-
-	return entries, nil
+	return e.entries("readdir", path)
 }
 
 func (e *dirEntry) readfile(path string) ([]byte, error) {
@@ -111,57 +129,16 @@ func (e *dirEntry) readfile(path string) ([]byte, error) {
 }
 
 func (e *dirEntry) entries(op, path string) ([]fs.DirEntry, error) {
-	if !e.sorted {
-		sort.Sort(entriesByName(e._entries))
-	}
-
-	return e._entries, nil
-}
-
-func (e *dirEntry) open() (fs.File, error) {
-	return &file{e, nil, 0, false}, nil
-}
+	children := e.idx.children[e.idx.childStart[e.id]:e.idx.childStart[e.id+1]]
 
-type fakeDirFileInfo string
-
-var _ fs.FileInfo = fakeDirFileInfo("")
-
-func (e fakeDirFileInfo) Name() string {
-	return string(e)
-}
-
-func (fakeDirFileInfo) Size() int64 {
-	return 0
-}
-
-func (fakeDirFileInfo) Mode() fs.FileMode {
-	return fs.ModeDir
-}
-
-func (fakeDirFileInfo) ModTime() time.Time {
-	return time.Time{}
-}
-
-func (fakeDirFileInfo) IsDir() bool {
-	return true
-}
-
-func (fakeDirFileInfo) Sys() interface{} {
-	return nil
-}
-
-type entriesByName []fs.DirEntry
-
-var _ sort.Interface = entriesByName{}
-
-func (entries entriesByName) Less(i, j int) bool {
-	return entries[i].Name() < entries[j].Name()
-}
+	entries := make([]fs.DirEntry, len(children))
+	for i, id := range children {
+		entries[i] = e.idx.entry(id)
+	}
 
-func (entries entriesByName) Len() int {
-	return len(entries)
+	return entries, nil
 }
 
-func (entries entriesByName) Swap(i, j int) {
-	entries[i], entries[j] = entries[j], entries[i]
+func (e *dirEntry) open(path string) (*file, error) {
+	return &file{entry: e}, nil
 }
@@ -9,6 +9,16 @@ import (
 var (
 	ErrNotDir = errors.New("not a directory")
 	ErrDir    = errors.New("is a directory")
+
+	// ErrNameCollision is returned by New when two entries have the same lookup key,
+	// see WithCaseInsensitive and WithUnicodeNormalization.
+	ErrNameCollision = errors.New("name collision")
+
+	// ErrNoXattr is returned by Getxattr when a file has no extended attribute with the given name.
+	ErrNoXattr = errors.New("no such extended attribute")
+
+	// ErrSpecialFile is returned when opening or reading a device, named pipe or socket.
+	ErrSpecialFile = errors.New("is a special file")
 )
 
 func newErrNotDir(op, path string) error {
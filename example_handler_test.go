@@ -0,0 +1,34 @@
+package tarfs_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+
+	"github.com/nlepage/go-tarfs"
+)
+
+// Example_handler demonstrates how to serve the contents of a tar file using tarfs.Handler
+func Example_handler() {
+	tfs, err := tarfs.NewFromFile("test.tar")
+	if err != nil {
+		panic(err)
+	}
+	defer tfs.Close()
+
+	srv := httptest.NewServer(tarfs.Handler(tfs, &tarfs.HandlerOptions{Precompressed: true}))
+	defer srv.Close()
+
+	res, err := srv.Client().Get(srv.URL + "/dir1/dir11/file111")
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := io.Copy(os.Stdout, res.Body); err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+
+	// Output:
+	// file111
+}
@@ -0,0 +1,433 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// ExtractOptions configures Extract.
+type ExtractOptions struct {
+	// PreserveOwner sets the uid and gid of the extracted files to the ones from the archive.
+	PreserveOwner bool
+
+	// PreserveXattrs sets the extended attributes from the archive on the extracted files.
+	// It is supported on Linux only.
+	PreserveXattrs bool
+
+	// DirMode is the permission of the directories having none,
+	// such as the ones which have no header in the archive.
+	// Defaults to 0755.
+	DirMode fs.FileMode
+}
+
+// Extract writes the contents of fsys to the directory dest, which is created if needed.
+// Directories, regular files, symbolic links and hard links are created with their mode
+// and modification time, other file types are skipped.
+// Extract refuses to write outside of dest, be it with ".." in the links of the archive
+// or by following symbolic links.
+func Extract(fsys fs.FS, dest string, opts *ExtractOptions) error {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	return newExtractor(fsys, root, opts).extract()
+}
+
+type extractor struct {
+	fsys fs.FS
+	root *os.Root
+	opts *ExtractOptions
+
+	// archive holds the targets of the hard links of fsys, which are named relatively to the root of the archive,
+	// and prefix is the name of fsys in archive followed by a slash, if fsys is a sub directory of a tar fs.FS
+	archive fs.FS
+	prefix  string
+
+	dirs  []extracted // finalized once their contents are written
+	links []extracted // created once all regular files are written
+}
+
+func newExtractor(fsys fs.FS, root *os.Root, opts *ExtractOptions) *extractor {
+	x := &extractor{fsys: fsys, root: root, opts: opts, archive: fsys}
+
+	if tfs, ok := fsys.(*tarfs); ok && tfs.root != 0 {
+		x.archive = &tarfs{
+			idx:      tfs.idx,
+			zeroCopy: tfs.zeroCopy,
+			parallel: tfs.parallel,
+			h:        tfs.h,
+		}
+		x.prefix = tfs.idx.path(0, tfs.root) + "/"
+	}
+
+	return x
+}
+
+type extracted struct {
+	name string
+	info fs.FileInfo
+}
+
+func (x *extractor) extract() error {
+	if err := fs.WalkDir(x.fsys, ".", x.walk); err != nil {
+		return err
+	}
+
+	for _, l := range x.links {
+		if err := x.link(l.name, l.info); err != nil {
+			return err
+		}
+	}
+
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		if err := x.finalizeDir(x.dirs[i].name, x.dirs[i].info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (x *extractor) walk(name string, d fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if name == "." {
+		return nil
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		if err := x.mkdir(name); err != nil {
+			return err
+		}
+		x.dirs = append(x.dirs, extracted{name, info})
+	case isHardLink(info) || info.Mode()&fs.ModeSymlink != 0:
+		x.links = append(x.links, extracted{name, info})
+	case info.Mode().IsRegular():
+		return x.writeFile(name, info)
+	}
+
+	return nil
+}
+
+func (x *extractor) mkdir(name string) error {
+	if fi, err := x.root.Lstat(name); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		if err := x.root.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return x.root.Mkdir(name, 0700)
+}
+
+func (x *extractor) writeFile(name string, info fs.FileInfo) error {
+	if fi, err := x.root.Lstat(name); err == nil && !fi.Mode().IsRegular() {
+		if err := x.root.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+
+	dst, err := x.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := x.copyFile(name, dst, info); err != nil {
+		dst.Close()
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return x.chtimes(name, info)
+}
+
+// copyFile copies the content and attributes of the file name of fsys to dst.
+func (x *extractor) copyFile(name string, dst *os.File, info fs.FileInfo) error {
+	src, err := x.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+
+	return x.setAttrs(name, dst, info)
+}
+
+func (x *extractor) finalizeDir(name string, info fs.FileInfo) error {
+	dir, err := x.root.Open(name)
+	if err != nil {
+		return err
+	}
+
+	if err := x.setAttrs(name, dir, info); err != nil {
+		dir.Close()
+		return err
+	}
+
+	if err := dir.Close(); err != nil {
+		return err
+	}
+
+	return x.chtimes(name, info)
+}
+
+func (x *extractor) link(name string, info fs.FileInfo) error {
+	if _, err := x.root.Lstat(name); err == nil {
+		if err := x.root.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return x.createLink(name, name, info)
+}
+
+// createLink creates dst as a copy of the symbolic or hard link name of fsys.
+func (x *extractor) createLink(name, dst string, info fs.FileInfo) error {
+	if isHardLink(info) {
+		target, err := hardLinkTarget(name, info)
+		if err != nil {
+			return err
+		}
+		if rel, ok := x.linkTarget(name, target); ok {
+			return x.root.Link(rel, dst)
+		}
+		return x.copyLinkTarget(name, target, dst)
+	}
+
+	target, err := readLink(x.fsys, name, info)
+	if err != nil {
+		return err
+	}
+
+	if err := x.root.Symlink(target, dst); err != nil {
+		return err
+	}
+
+	if !x.opts.PreserveOwner {
+		return nil
+	}
+
+	h, err := fileHeader(info)
+	if err != nil || h == nil {
+		return err
+	}
+
+	return x.root.Lchown(dst, h.Uid, h.Gid)
+}
+
+// linkTarget returns the name in fsys of target, the target of the hard link name,
+// and false if the target is outside of fsys.
+func (x *extractor) linkTarget(name, target string) (string, bool) {
+	if mount, ok := x.mount(name); ok {
+		return mount + "/" + target, true
+	}
+	return strings.CutPrefix(target, x.prefix)
+}
+
+// mount returns the name in fsys of the nested archive holding the file name, if any, see WithNestedArchives.
+// The targets of the hard links of a nested archive are named relatively to its root.
+func (x *extractor) mount(name string) (string, bool) {
+	tfs, ok := x.fsys.(*tarfs)
+	if !ok {
+		return "", false
+	}
+
+	owner, id, err := tfs.resolve("link", name)
+	if err != nil || owner.idx == tfs.idx {
+		return "", false
+	}
+
+	return strings.TrimSuffix(name, "/"+owner.idx.path(owner.root, id)), true
+}
+
+// copyLinkTarget creates dst as a copy of target, the target of the hard link name which is outside of fsys.
+func (x *extractor) copyLinkTarget(name, target, dst string) error {
+	info, err := fs.Stat(x.archive, target)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &os.LinkError{Op: "link", Old: target, New: name, Err: fs.ErrInvalid}
+	}
+
+	src, err := x.archive.Open(target)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	f, err := x.root.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := x.setAttrs(name, f, info); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return x.chtimes(dst, info)
+}
+
+// setAttrs sets the permission, owner and extended attributes of f from the file name described by info.
+func (x *extractor) setAttrs(name string, f *os.File, info fs.FileInfo) error {
+	h, err := fileHeader(info)
+	if err != nil {
+		return err
+	}
+
+	if h != nil && x.opts.PreserveOwner {
+		if err := f.Chown(h.Uid, h.Gid); err != nil {
+			return err
+		}
+	}
+
+	// chmod after chown, which may clear setuid and setgid bits
+	if err := f.Chmod(x.mode(info)); err != nil {
+		return err
+	}
+
+	if x.opts.PreserveXattrs {
+		xattrs, err := readXattrs(x.fsys, name)
+		if err != nil {
+			return err
+		}
+		if err := setXattrs(f, xattrs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (x *extractor) chtimes(name string, info fs.FileInfo) error {
+	mtime := info.ModTime()
+	if mtime.IsZero() {
+		return nil
+	}
+
+	h, err := fileHeader(info)
+	if err != nil {
+		return err
+	}
+
+	atime := mtime
+	if h != nil && !h.AccessTime.IsZero() {
+		atime = h.AccessTime
+	}
+
+	return x.root.Chtimes(name, atime, mtime)
+}
+
+// mode returns the permission and special bits to set on the file described by info.
+func (x *extractor) mode(info fs.FileInfo) fs.FileMode {
+	mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	if info.IsDir() && mode.Perm() == 0 {
+		if x.opts.DirMode == 0 {
+			return mode | 0755
+		}
+		return mode | x.opts.DirMode.Perm()
+	}
+	return mode
+}
+
+// isHardLink reports whether info describes a hard link,
+// which is known without reading the header again for the files of a tar fs.FS.
+func isHardLink(info fs.FileInfo) bool {
+	if fi, ok := info.(nodeInfo); ok {
+		return fi.idx.typeflag[fi.id] == tar.TypeLink
+	}
+	h, ok := info.Sys().(*tar.Header)
+	return ok && h.Typeflag == tar.TypeLink
+}
+
+// hardLinkTarget returns the name of the target of the hard link name described by info.
+func hardLinkTarget(name string, info fs.FileInfo) (string, error) {
+	h, err := fileHeader(info)
+	if err != nil {
+		return "", err
+	}
+	if h == nil {
+		return "", &os.LinkError{Op: "link", New: name, Err: fs.ErrInvalid}
+	}
+
+	target := path.Clean(strings.TrimPrefix(h.Linkname, "/"))
+	if !fs.ValidPath(target) {
+		return "", &os.LinkError{Op: "link", Old: h.Linkname, New: name, Err: fs.ErrInvalid}
+	}
+	return target, nil
+}
+
+// readLink returns the target of the symbolic link name.
+func readLink(fsys fs.FS, name string, info fs.FileInfo) (string, error) {
+	h, err := fileHeader(info)
+	if err != nil {
+		return "", err
+	}
+	if h != nil {
+		return h.Linkname, nil
+	}
+	return fs.ReadLink(fsys, name)
+}
+
+// readXattrs returns the extended attributes of the file name of fsys,
+// or none if fsys does not support extended attributes.
+func readXattrs(fsys fs.FS, name string) (map[string]string, error) {
+	attrs, err := Listxattr(fsys, name)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string]string, len(attrs))
+	for _, attr := range attrs {
+		value, err := Getxattr(fsys, name, attr)
+		if err != nil {
+			return nil, err
+		}
+		xattrs[attr] = string(value)
+	}
+	return xattrs, nil
+}
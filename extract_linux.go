@@ -0,0 +1,35 @@
+package tarfs
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func setXattrs(f *os.File, xattrs map[string]string) error {
+	for attr, value := range xattrs {
+		if err := fsetxattr(int(f.Fd()), attr, []byte(value)); err != nil {
+			return &os.PathError{Op: "setxattr", Path: f.Name(), Err: err}
+		}
+	}
+	return nil
+}
+
+func fsetxattr(fd int, attr string, value []byte) error {
+	attrp, err := syscall.BytePtrFromString(attr)
+	if err != nil {
+		return err
+	}
+
+	var valuep unsafe.Pointer
+	if len(value) > 0 {
+		valuep = unsafe.Pointer(&value[0])
+	}
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_FSETXATTR, uintptr(fd), uintptr(unsafe.Pointer(attrp)), uintptr(valuep), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package tarfs
+
+import (
+	"errors"
+	"os"
+)
+
+func setXattrs(f *os.File, xattrs map[string]string) error {
+	if len(xattrs) == 0 {
+		return nil
+	}
+	return &os.PathError{Op: "setxattr", Path: f.Name(), Err: errors.ErrUnsupported}
+}
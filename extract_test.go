@@ -0,0 +1,277 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	dest := t.TempDir()
+
+	require.NoError(Extract(tfs, dest, nil))
+
+	for _, name := range []string{"bar", "foo", "dir1/dir11/file111", "dir1/file11", "dir1/file12", "dir2/dir21/file211", "dir2/dir21/file212"} {
+		expected, err := fs.Stat(tfs, name)
+		require.NoError(err)
+
+		actual, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		if !assert.NoErrorf(err, "when os.Stat(%#v)", name) {
+			continue
+		}
+
+		assert.Equalf(expected.Mode(), actual.Mode(), "mode of %#v", name)
+		assert.Truef(expected.ModTime().Equal(actual.ModTime()), "mtime of %#v", name)
+
+		content, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if assert.NoErrorf(err, "when os.ReadFile(%#v)", name) {
+			assert.Equalf(filepath.Base(name), string(content), "content of %#v", name)
+		}
+	}
+
+	for _, name := range []string{"dir1", "dir1/dir11", "dir2", "dir2/dir21"} {
+		fi, err := os.Stat(filepath.Join(dest, filepath.FromSlash(name)))
+		if assert.NoErrorf(err, "when os.Stat(%#v)", name) {
+			assert.Truef(fi.IsDir(), "%#v is a directory", name)
+		}
+	}
+}
+
+func TestExtractSub(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	subfs, err := fs.Sub(tfs, "dir1")
+	require.NoError(err)
+
+	dest := t.TempDir()
+
+	require.NoError(Extract(subfs, dest, nil))
+
+	content, err := os.ReadFile(filepath.Join(dest, "dir11", "file111"))
+	require.NoError(err)
+	require.Equal("file111", string(content))
+
+	_, err = os.Stat(filepath.Join(dest, "foo"))
+	require.ErrorIs(err, fs.ErrNotExist)
+
+	// Hard links are named relatively to the root of the archive
+	tfs2, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "outside", Typeflag: tar.TypeReg, Mode: 0640}, "outside"},
+		testEntry{&tar.Header{Name: "d/file", Typeflag: tar.TypeReg, Mode: 0644}, "file"},
+		testEntry{&tar.Header{Name: "d/hardlink", Typeflag: tar.TypeLink, Linkname: "d/file", Mode: 0644}, ""},
+		testEntry{&tar.Header{Name: "d/copy", Typeflag: tar.TypeLink, Linkname: "outside", Mode: 0640}, ""},
+	)))
+	require.NoError(err)
+
+	subfs, err = fs.Sub(tfs2, "d")
+	require.NoError(err)
+
+	dest = t.TempDir()
+
+	require.NoError(Extract(subfs, dest, nil))
+
+	file, err := os.Stat(filepath.Join(dest, "file"))
+	require.NoError(err)
+	hardlink, err := os.Stat(filepath.Join(dest, "hardlink"))
+	require.NoError(err)
+	require.True(os.SameFile(file, hardlink))
+
+	// The targets outside of the sub directory are copied
+	content, err = os.ReadFile(filepath.Join(dest, "copy"))
+	require.NoError(err)
+	require.Equal("outside", string(content))
+	copied, err := os.Stat(filepath.Join(dest, "copy"))
+	require.NoError(err)
+	require.Equal(fs.FileMode(0640), copied.Mode())
+
+	_, err = os.Stat(filepath.Join(dest, "outside"))
+	require.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestExtractDirMode(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test-no-directory-entries.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	dest := t.TempDir()
+
+	require.NoError(Extract(tfs, dest, &ExtractOptions{DirMode: 0750}))
+
+	fi, err := os.Stat(filepath.Join(dest, "dir1", "dir11"))
+	require.NoError(err)
+	require.Equal(fs.ModeDir|0750, fi.Mode())
+}
+
+func TestExtractLinks(t *testing.T) {
+	require := require.New(t)
+
+	mtime := time.Date(2023, 8, 21, 10, 0, 0, 0, time.UTC)
+
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "dir/file", Typeflag: tar.TypeReg, Mode: 0640, ModTime: mtime}, "content"},
+		testEntry{&tar.Header{Name: "dir/symlink", Typeflag: tar.TypeSymlink, Linkname: "file", Mode: 0777, ModTime: mtime}, ""},
+		testEntry{&tar.Header{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "dir/file", Mode: 0640, ModTime: mtime}, ""},
+	)))
+	require.NoError(err)
+
+	dest := t.TempDir()
+
+	require.NoError(Extract(tfs, dest, nil))
+
+	target, err := os.Readlink(filepath.Join(dest, "dir", "symlink"))
+	require.NoError(err)
+	require.Equal("file", target)
+
+	file, err := os.Stat(filepath.Join(dest, "dir", "file"))
+	require.NoError(err)
+	require.Equal(fs.FileMode(0640), file.Mode())
+
+	hardlink, err := os.Stat(filepath.Join(dest, "hardlink"))
+	require.NoError(err)
+	require.True(os.SameFile(file, hardlink))
+}
+
+// failingReaderAt fails the next read at offset fail.
+type failingReaderAt struct {
+	*bytes.Reader
+	fail int64
+}
+
+var errFailingRead = errors.New("failing read")
+
+func (r *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off == r.fail {
+		r.fail = -1
+		return 0, errFailingRead
+	}
+	return r.Reader.ReadAt(p, off)
+}
+
+func TestExtractHeaderReadError(t *testing.T) {
+	require := require.New(t)
+
+	ra := &failingReaderAt{bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644}, "a"},
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeLink, Linkname: "a", Mode: 0644}, ""},
+	)), -1}
+
+	tfs, err := New(ra)
+	require.NoError(err)
+
+	dest := t.TempDir()
+
+	// The header of b is read again from the archive for its link name
+	ra.fail = 2 * blockSize
+	require.ErrorIs(Extract(tfs, dest, nil), errFailingRead)
+
+	require.NoError(Extract(tfs, dest, nil))
+
+	a, err := os.Stat(filepath.Join(dest, "a"))
+	require.NoError(err)
+	b, err := os.Stat(filepath.Join(dest, "b"))
+	require.NoError(err)
+	require.True(os.SameFile(a, b))
+}
+
+func newNestedHardLinkArchive(t *testing.T) []byte {
+	t.Helper()
+
+	inner := newTestArchive(t,
+		testEntry{&tar.Header{Name: "x", Typeflag: tar.TypeReg, Mode: 0644}, "INNER x"},
+		testEntry{&tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "x", Mode: 0644}, ""},
+	)
+
+	return newTestArchive(t,
+		testEntry{&tar.Header{Name: "x", Typeflag: tar.TypeReg, Mode: 0644}, "OUTER x"},
+		testEntry{&tar.Header{Name: "comp.tar", Typeflag: tar.TypeReg, Mode: 0644}, string(inner)},
+	)
+}
+
+func TestExtractNestedHardLink(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := New(bytes.NewReader(newNestedHardLinkArchive(t)), WithNestedArchives())
+	require.NoError(err)
+
+	dest := t.TempDir()
+
+	require.NoError(Extract(tfs, dest, nil))
+
+	// Hard links are named relatively to the root of the nested archive holding them
+	content, err := os.ReadFile(filepath.Join(dest, "comp.tar", "link"))
+	require.NoError(err)
+	require.Equal("INNER x", string(content))
+
+	x, err := os.Stat(filepath.Join(dest, "comp.tar", "x"))
+	require.NoError(err)
+	link, err := os.Stat(filepath.Join(dest, "comp.tar", "link"))
+	require.NoError(err)
+	require.True(os.SameFile(x, link))
+}
+
+func TestExtractHardLinkEscape(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "../outside", Mode: 0644}, ""},
+	)))
+	require.NoError(err)
+
+	parent := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(parent, "outside"), []byte("outside"), 0644))
+
+	dest := filepath.Join(parent, "dest")
+
+	require.Error(Extract(tfs, dest, nil))
+
+	_, err = os.Lstat(filepath.Join(dest, "hardlink"))
+	require.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestExtractThroughSymlink(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	outside := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(outside, "foo"), []byte("outside"), 0644))
+
+	dest := t.TempDir()
+	require.NoError(os.Symlink(filepath.Join(outside, "foo"), filepath.Join(dest, "foo")))
+	require.NoError(os.Symlink(outside, filepath.Join(dest, "dir1")))
+
+	require.NoError(Extract(tfs, dest, nil))
+
+	content, err := os.ReadFile(filepath.Join(outside, "foo"))
+	require.NoError(err)
+	require.Equal("outside", string(content))
+
+	_, err = os.Stat(filepath.Join(outside, "file11"))
+	require.ErrorIs(err, fs.ErrNotExist)
+
+	fi, err := os.Lstat(filepath.Join(dest, "foo"))
+	require.NoError(err)
+	require.True(fi.Mode().IsRegular())
+}
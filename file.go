@@ -1,15 +1,22 @@
 package tarfs
 
 import (
+	"errors"
 	"io"
 	"io/fs"
+	"sync"
 )
 
 type file struct {
 	entry
 	r          io.ReadSeeker
 	readDirPos int
-	closed     bool
+	h          *handle
+	closed     bool // guarded by h.mu
+	atMu       sync.Mutex
+	at         *readSeeker // guarded by atMu, used by ReadAt for sparse entries
+	parallel   *parallelOptions
+	dirEntries []fs.DirEntry // entries of a directory, listed by the first call to ReadDir
 }
 
 var _ fs.File = &file{}
@@ -17,7 +24,7 @@ var _ fs.File = &file{}
 func (f *file) Stat() (fs.FileInfo, error) {
 	const op = "stat"
 
-	if f.closed {
+	if f.isClosed() {
 		return nil, newErrClosed(op, f.Name())
 	}
 
@@ -27,7 +34,7 @@ func (f *file) Stat() (fs.FileInfo, error) {
 func (f *file) Read(b []byte) (int, error) {
 	const op = "read"
 
-	if f.closed {
+	if f.isClosed() {
 		return 0, newErrClosed(op, f.Name())
 	}
 
@@ -41,22 +48,25 @@ func (f *file) Read(b []byte) (int, error) {
 func (f *file) Close() error {
 	const op = "close"
 
-	if f.closed {
+	if !f.h.closeFile(f) {
 		return newErrClosed(op, f.Name())
 	}
 
 	f.r = nil
-	f.closed = true
 
 	return nil
 }
 
+func (f *file) isClosed() bool {
+	return f.h.isFileClosed(f)
+}
+
 var _ io.Seeker = &file{}
 
 func (f *file) Seek(offset int64, whence int) (int64, error) {
 	const op = "seek"
 
-	if f.closed {
+	if f.isClosed() {
 		return 0, newErrClosed(op, f.Name())
 	}
 
@@ -67,19 +77,103 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 	return f.r.Seek(offset, whence)
 }
 
+var _ io.ReaderAt = &file{}
+
+// ReadAt reads len(b) bytes from the file starting at off.
+// It does not affect the offset used by Read and Seek, and may be called concurrently.
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	const op = "readat"
+
+	if f.isClosed() {
+		return 0, newErrClosed(op, f.Name())
+	}
+
+	if f.IsDir() {
+		return 0, newErrDir(op, f.Name())
+	}
+
+	if off < 0 {
+		return 0, newErr(op, f.Name(), errors.New("negative offset"))
+	}
+
+	e := f.entry.(*regEntry)
+	if e.dataOffset >= 0 {
+		n, err := io.NewSectionReader(e.ra, e.dataOffset, e.size()).ReadAt(b, off)
+		if err == io.EOF && e.truncated && off+int64(n) < e.size() {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	// Sparse entries are read sequentially, continuing from the previous call when possible
+	f.atMu.Lock()
+	defer f.atMu.Unlock()
+
+	if f.at == nil {
+		r, err := e.reader()
+		if err != nil {
+			return 0, err
+		}
+		f.at = &readSeeker{&readCounter{r, 0}, e}
+	}
+
+	if _, err := f.at.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(f.at, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+var _ io.WriterTo = &file{}
+
+// WriteTo writes the content of the file from the current offset to w.
+// See WithParallelReads.
+func (f *file) WriteTo(w io.Writer) (int64, error) {
+	const op = "writeto"
+
+	if f.isClosed() {
+		return 0, newErrClosed(op, f.Name())
+	}
+
+	if f.IsDir() {
+		return 0, newErrDir(op, f.Name())
+	}
+
+	if e, ok := f.entry.(*regEntry); ok {
+		off, err := f.r.Seek(0, io.SeekCurrent)
+		if err == nil && e.parallelizable(f.parallel, off) {
+			n, err := e.writeParallel(w, off, f.parallel)
+			if _, seekErr := f.r.Seek(off+n, io.SeekStart); err == nil {
+				err = seekErr
+			}
+			return n, err
+		}
+	}
+
+	return io.Copy(w, struct{ io.Reader }{f.r})
+}
+
 var _ fs.ReadDirFile = &file{}
 
 func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
 	const op = "readdir"
 
-	if f.closed {
+	if f.isClosed() {
 		return nil, newErrClosed(op, f.Name())
 	}
 
-	allEntries, err := f.entry.entries(op, f.Name())
-	if err != nil {
-		return nil, err
+	if f.dirEntries == nil {
+		entries, err := f.entry.entries(op, f.Name())
+		if err != nil {
+			return nil, err
+		}
+		f.dirEntries = entries
 	}
+	allEntries := f.dirEntries
 
 	if f.readDirPos >= len(allEntries) {
 		if n <= 0 {
@@ -2,10 +2,12 @@ package tarfs
 
 import (
 	"archive/tar"
-	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"io/fs"
 	"path"
+	"sort"
 	"strings"
 )
 
@@ -13,83 +15,253 @@ const (
 	blockSize = 512 // Size of each block in a tar stream
 )
 
+// FS is a tar fs.FS holding resources which are released by Close.
+type FS interface {
+	fs.FS
+	io.Closer
+}
+
 type tarfs struct {
-	entries map[string]fs.DirEntry
+	idx *index
+	// root is the node of idx which is the root of the fs.FS, see Sub
+	root     uint32
+	zeroCopy bool
+	parallel *parallelOptions
+	h        *handle
 }
 
-var _ fs.FS = &tarfs{}
+var _ FS = &tarfs{}
 
 // New creates a new tar fs.FS from r.
 // If r implements io.ReaderAt:
 // - files content are not stored in memory
-// - r must stay opened while using the fs.FS
-func New(r io.Reader) (fs.FS, error) {
-	tfs := &tarfs{make(map[string]fs.DirEntry)}
-	tfs.entries["."] = newDirEntry(fs.FileInfoToDirEntry(fakeDirFileInfo(".")))
+// - r must stay opened while using the fs.FS, unless WithOwnedReader is given
+func New(r io.Reader, opts ...Option) (FS, error) {
+	return NewContext(context.Background(), r, opts...)
+}
+
+// NewContext is like New, but stops reading and indexing the archive and returns ctx.Err() when ctx is done.
+func NewContext(ctx context.Context, r io.Reader, opts ...Option) (FS, error) {
+	o := newOptions(opts)
 
 	ra, isReaderAt := r.(readReaderAt)
 	if !isReaderAt {
-		buf, err := io.ReadAll(r)
+		br := &bufferingReader{ctx: ctx, r: r, progress: o.progress}
+		buf, err := io.ReadAll(br)
 		if err != nil {
 			return nil, err
 		}
-		ra = bytes.NewReader(buf)
+		ra = newMemReader(buf)
+
+		if progress := o.progress; progress != nil {
+			o.progress = func(p Progress) {
+				p.Buffered = br.n
+				progress(p)
+			}
+		}
+	}
+
+	if _, isMem := ra.(*memReader); o.cache != nil && !isMem {
+		size := readerAtSize(ra)
+		cache := newBlockCache(ra, size, o.cache)
+		if size < 0 {
+			size = 1<<63 - 1
+		}
+		ra = io.NewSectionReader(cache, 0, size)
+	}
+
+	tfs, err := newTarfs(ctx, ra, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, isCloser := r.(io.Closer); isCloser && o.ownedReader {
+		tfs.h.close = c.Close
 	}
 
-	var cr readCounterIface
-	if rs, isReadSeeker := ra.(io.ReadSeeker); isReadSeeker {
-		cr = &readSeekCounter{ReadSeeker: rs}
-	} else {
-		cr = &readCounter{Reader: ra}
+	return tfs, nil
+}
+
+func newTarfs(ctx context.Context, ra readReaderAt, o *options) (*tarfs, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	tfs := &tarfs{
+		idx:      newIndex(ra, o),
+		zeroCopy: o.zeroCopy,
+		parallel: o.parallel,
+		h:        newHandle(),
+	}
+	idx := tfs.idx
+	idx.h = tfs.h
+
+	cr, err := newReadCounter(ra, 0)
+	if err != nil {
+		return nil, err
 	}
 
 	tr := tar.NewReader(cr)
 
+	// member is the offset of the current member of concatenated archives
+	member := int64(0)
+	// next is the offset of the headers of the next entry, or -1 if it is unknown
+	next := int64(0)
+
+	var rec *recoverer
+	if o.recovery {
+		rec = newRecoverer(idx, o)
+	}
+
+	var progress Progress
+
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		h, err := tr.Next()
+		if o.ignoreZeros && (err == io.EOF || err == tar.ErrHeader && isLoneZeroBlock(ra, cr.Count())) {
+			off := cr.Count()
+			if err != io.EOF {
+				off -= blockSize
+			}
+
+			start, startErr := nextMember(ra, off)
+			if startErr != nil {
+				return nil, startErr
+			}
+			if start > member {
+				if cr, err = newReadCounter(ra, start); err != nil {
+					return nil, err
+				}
+				tr = tar.NewReader(cr)
+				member, next = start, start
+				continue
+			}
+		}
 		if err == io.EOF {
 			break
 		}
+		if err != nil && rec != nil {
+			resync, recErr := rec.recover(err, cr.Count())
+			if recErr != nil {
+				return nil, recErr
+			}
+			if resync < 0 {
+				break
+			}
+			if cr, err = newReadCounter(ra, resync); err != nil {
+				return nil, err
+			}
+			tr = tar.NewReader(cr)
+			member, next = resync, resync
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
+
+		offset := next
+		next = entryEnd(h, cr.Count())
+
+		if rec != nil {
+			rec.next(next)
+		}
+		if o.progress != nil {
+			progress.Entries++
+			progress.Bytes = cr.Count()
+			o.progress(progress)
+		}
 		if h.Typeflag == tar.TypeXGlobalHeader {
 			continue
 		}
 
+		raw := h.Name
+		escaped := decodeNames(h, o.decodeName)
+
 		name := path.Clean(h.Name)
 		if name == "." {
 			continue
 		}
 
-		de := fs.FileInfoToDirEntry(h.FileInfo())
+		if other, ok := idx.checkEscaped(name, path.Clean(raw), escaped); !ok {
+			return nil, newErr("new", name, fmt.Errorf("%w with %q", ErrNameCollision, other))
+		}
+
+		fi := h.FileInfo()
+
+		if idx.normalize != nil {
+			indexed := idx.canonical(name)
+			if id, ok := idx.find(0, indexed); ok && indexed != name {
+				if !fi.IsDir() || !idx.mode[id].IsDir() {
+					return nil, newErr("new", name, fmt.Errorf("%w with %s", ErrNameCollision, indexed))
+				}
+				// Keep the first spelling of the directory,
+				// and its first header, unless it was created by the entries under it
+				if idx.hasHeader(id) {
+					continue
+				}
+			}
+			name = indexed
+		}
+
+		id := idx.node(name, fi.IsDir())
+
+		dataOffset := int64(-1)
+		if !fi.IsDir() && !isSparse(h) {
+			dataOffset = cr.Count()
+		}
+
+		idx.set(id, h, fi, offset, cr.Count()-blockSize, dataOffset)
+
+		if rec != nil && dataOffset >= 0 {
+			rec.last = int64(id)
+		}
 
-		if h.FileInfo().IsDir() {
-			tfs.append(name, newDirEntry(de))
-		} else {
-			tfs.append(name, &regEntry{de, name, ra, cr.Count() - blockSize})
+		if o.nested != nil && fi.Mode().IsRegular() && o.nested(name) {
+			idx.nested[id] = &nestedEntry{regEntry: idx.regEntry(id, fs.FileInfoToDirEntry(nodeInfo{idx, id})), o: o, h: tfs.h}
 		}
 	}
 
+	idx.finish()
+
 	return tfs, nil
 }
 
-func (tfs *tarfs) append(name string, e fs.DirEntry) {
-	tfs.entries[name] = e
-
-	dir := path.Dir(name)
+// entryEnd returns the offset of the end of the entry whose header h ends at off,
+// or -1 if it is unknown.
+func entryEnd(h *tar.Header, off int64) int64 {
+	if isSparse(h) {
+		return -1
+	}
 
-	if parent, ok := tfs.entries[dir]; ok {
-		parent := parent.(*dirEntry)
-		parent.append(e)
-		return
+	size := h.Size
+	switch h.Typeflag {
+	case tar.TypeLink, tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeDir, tar.TypeFifo, tar.TypeXGlobalHeader:
+		size = 0
 	}
 
-	parent := newDirEntry(fs.FileInfoToDirEntry(fakeDirFileInfo(path.Base(dir))))
+	return alignBlock(off) + alignBlock(size)
+}
 
-	tfs.append(dir, parent)
+// alignBlock returns off rounded up to a multiple of blockSize.
+func alignBlock(off int64) int64 {
+	return (off + blockSize - 1) / blockSize * blockSize
+}
 
-	parent.append(e)
+// isSparse reports whether h is the header of a sparse file,
+// whose data is not stored contiguously in the archive.
+func isSparse(h *tar.Header) bool {
+	if h.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
 }
 
 func (tfs *tarfs) Open(name string) (fs.File, error) {
@@ -100,7 +272,18 @@ func (tfs *tarfs) Open(name string) (fs.File, error) {
 		return nil, err
 	}
 
-	return e.open()
+	f, err := e.open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.parallel = tfs.parallel
+
+	if !tfs.h.open(f) {
+		return nil, newErrClosed(op, name)
+	}
+
+	return f, nil
 }
 
 var _ fs.ReadDirFS = &tarfs{}
@@ -122,6 +305,16 @@ func (tfs *tarfs) ReadFile(name string) ([]byte, error) {
 		return nil, err
 	}
 
+	if re, ok := e.(*regEntry); ok && tfs.zeroCopy {
+		if b, ok := re.bytes(); ok {
+			return b, nil
+		}
+	}
+
+	if re, ok := e.(*regEntry); ok && re.parallelizable(tfs.parallel, 0) {
+		return re.readParallel(tfs.parallel)
+	}
+
 	return e.readfile(name)
 }
 
@@ -139,15 +332,35 @@ func (tfs *tarfs) Stat(name string) (fs.FileInfo, error) {
 var _ fs.GlobFS = &tarfs{}
 
 func (tfs *tarfs) Glob(pattern string) (matches []string, _ error) {
-	for name := range tfs.entries {
+	if tfs.h.isClosed() {
+		return nil, newErrClosed("glob", pattern)
+	}
+
+	err := tfs.idx.walk(tfs.root, func(id uint32, name string) error {
 		match, err := path.Match(pattern, name)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if match {
 			matches = append(matches, name)
 		}
+
+		if ne, ok := tfs.idx.nested[id]; ok {
+			nestedMatches, err := ne.glob(name, pattern)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, nestedMatches...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	sort.Strings(matches)
+
 	return
 }
 
@@ -165,18 +378,28 @@ func (tfs *tarfs) Sub(dir string) (fs.FS, error) {
 		return nil, err
 	}
 
-	subfs := &tarfs{make(map[string]fs.DirEntry)}
-
-	subfs.entries["."] = e
-
-	prefix := dir + "/"
-	for name, file := range tfs.entries {
-		if strings.HasPrefix(name, prefix) {
-			subfs.entries[strings.TrimPrefix(name, prefix)] = file
+	if ne, ok := e.(*nestedEntry); ok {
+		nestedfs, err := ne.mount()
+		if err != nil {
+			return nil, newErr(op, dir, err)
 		}
+		return nestedfs, nil
+	}
+
+	if !e.IsDir() {
+		return nil, newErrNotDir(op, dir)
 	}
 
-	return subfs, nil
+	// The sub fs.FS is a view of the same index, rooted at dir
+	owner, id, _ := tfs.resolve(op, dir)
+
+	return &tarfs{
+		idx:      owner.idx,
+		root:     id,
+		zeroCopy: owner.zeroCopy,
+		parallel: owner.parallel,
+		h:        owner.h,
+	}, nil
 }
 
 func (tfs *tarfs) get(op, path string) (entry, error) {
@@ -184,10 +407,78 @@ func (tfs *tarfs) get(op, path string) (entry, error) {
 		return nil, newErr(op, path, fs.ErrInvalid)
 	}
 
-	e, ok := tfs.entries[path]
-	if !ok {
-		return nil, newErrNotExist(op, path)
+	if tfs.h.isClosed() {
+		return nil, newErrClosed(op, path)
+	}
+
+	owner, id, err := tfs.resolve(op, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner.idx.entry(id), nil
+}
+
+// resolve returns the tarfs containing path, which is tfs or one of its nested archives,
+// and the node of path in its index.
+func (tfs *tarfs) resolve(op, path string) (*tarfs, uint32, error) {
+	if id, ok := tfs.lookup(path); ok {
+		return tfs, id, nil
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '/' {
+			continue
+		}
+
+		id, ok := tfs.lookup(path[:i])
+		if !ok {
+			break
+		}
+
+		ne, ok := tfs.idx.nested[id]
+		if !ok {
+			continue
+		}
+
+		nestedfs, err := ne.mount()
+		if err != nil {
+			return nil, 0, newErr(op, path, err)
+		}
+
+		owner, id, err := nestedfs.resolve(op, path[i+1:])
+		if pe, ok := err.(*fs.PathError); ok {
+			pe.Path = path
+		}
+
+		return owner, id, err
+	}
+
+	return nil, 0, newErrNotExist(op, path)
+}
+
+// lookup returns the node of path,
+// looking it up by its key if there is no entry named path.
+func (tfs *tarfs) lookup(path string) (uint32, bool) {
+	idx := tfs.idx
+
+	if id, ok := idx.find(tfs.root, path); ok {
+		return id, true
+	}
+
+	if idx.keys == nil {
+		return 0, false
+	}
+
+	prefix := ""
+	if tfs.root != 0 {
+		prefix = idx.path(0, tfs.root) + "/"
+	}
+
+	name, ok := idx.keys[idx.normalize(prefix+path)]
+	if !ok || !strings.HasPrefix(name, prefix) {
+		return 0, false
 	}
 
-	return e.(entry), nil
+	return idx.find(tfs.root, name[len(prefix):])
 }
@@ -1,14 +1,25 @@
 package tarfs
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"testing"
 	"testing/fstest"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/text/unicode/norm"
 )
 
 func TestFS(t *testing.T) {
@@ -149,6 +160,57 @@ func TestOpenThenSeekAfterEnd(t *testing.T) {
 	require.ErrorIs(err, io.EOF, "when ReadSeeker.Read([]byte)")
 }
 
+func TestReadAt(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	for _, name := range []string{"test.tar", "test-sparse.tar"} {
+		f, err := os.Open(name)
+		require.NoError(err)
+		defer f.Close()
+
+		tfs, err := New(f)
+		require.NoError(err)
+
+		entries, err := fs.ReadDir(tfs, ".")
+		require.NoError(err)
+
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+
+			content, err := fs.ReadFile(tfs, e.Name())
+			require.NoError(err)
+
+			f, err := tfs.Open(e.Name())
+			require.NoError(err)
+
+			ra := f.(io.ReaderAt)
+
+			for _, off := range []int{0, 1, len(content) - 2} {
+				b := make([]byte, 2)
+				n, err := ra.ReadAt(b, int64(off))
+				assert.NoErrorf(err, "ReadAt(%s, %d)", e.Name(), off)
+				assert.Equalf(content[off:off+2], b[:n], "ReadAt(%s, %d)", e.Name(), off)
+			}
+
+			b := make([]byte, 4)
+			n, err := ra.ReadAt(b, int64(len(content)-2))
+			assert.ErrorIsf(err, io.EOF, "ReadAt(%s) past end", e.Name())
+			assert.Equal(2, n)
+
+			// ReadAt does not move the offset of Read
+			all, err := io.ReadAll(f)
+			require.NoError(err)
+			assert.Equal(content, all)
+
+			require.NoError(f.Close())
+			_, err = ra.ReadAt(b, 0)
+			assert.ErrorIs(err, fs.ErrClosed)
+		}
+	}
+}
+
 func TestReadDir(t *testing.T) {
 	require, assert := require.New(t), assert.New(t)
 
@@ -335,6 +397,74 @@ func TestSubThenReadFile(t *testing.T) {
 	require.Equalf(content, string(b), "in %#v", name)
 }
 
+func TestSubView(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f)
+	require.NoError(err)
+
+	for _, name := range []string{"bar", "dir1/file11"} {
+		_, err := fs.Sub(tfs, name)
+		assert.ErrorIsf(err, ErrNotDir, "when fs.Sub(tfs, %#v)", name)
+	}
+
+	_, err = fs.Sub(tfs, "missing")
+	assert.ErrorIs(err, fs.ErrNotExist)
+
+	dir2, err := fs.Sub(tfs, "dir2")
+	require.NoError(err)
+	dir21, err := fs.Sub(dir2, "dir21")
+	require.NoError(err)
+	assert.Same(tfs.(*tarfs).idx, dir21.(*tarfs).idx)
+
+	for _, tc := range []struct {
+		dir  string
+		fsys fs.FS
+	}{
+		{"dir1", nil},
+		{"dir2", dir2},
+		{"dir2/dir21", dir21},
+	} {
+		subfs := tc.fsys
+		if subfs == nil {
+			subfs, err = fs.Sub(tfs, tc.dir)
+			require.NoError(err)
+		}
+
+		var expected []string
+		err := fs.WalkDir(tfs, tc.dir, func(name string, d fs.DirEntry, err error) error {
+			if name != tc.dir {
+				expected = append(expected, strings.TrimPrefix(name, tc.dir+"/"))
+			}
+			return err
+		})
+		require.NoError(err)
+
+		require.NoErrorf(fstest.TestFS(subfs, expected...), "in %#v", tc.dir)
+
+		for _, pattern := range []string{"*", "*/*", "*/file2*", "[d]*"} {
+			matches, err := fs.Glob(subfs, pattern)
+			require.NoError(err)
+
+			parentMatches, err := fs.Glob(tfs, tc.dir+"/"+pattern)
+			require.NoError(err)
+			for i := range parentMatches {
+				parentMatches[i] = strings.TrimPrefix(parentMatches[i], tc.dir+"/")
+			}
+			// Like the root of tfs, the root of subfs matches
+			if match, _ := path.Match(pattern, "."); match {
+				parentMatches = append([]string{"."}, parentMatches...)
+			}
+
+			assert.Equalf(parentMatches, matches, "fs.Glob(%#v, %#v)", tc.dir, pattern)
+		}
+	}
+}
+
 func TestReadOnDir(t *testing.T) {
 	require, assert := require.New(t), assert.New(t)
 
@@ -429,3 +559,520 @@ func TestIgnoreGlobalHeader(t *testing.T) {
 	err = fstest.TestFS(tfs, "bar", "dir1", "dir1/file11")
 	require.NoError(err)
 }
+
+func TestWithCaseInsensitive(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "Dir/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		testEntry{&tar.Header{Name: "Dir/ReadMe.TXT", Typeflag: tar.TypeReg, Mode: 0644}, "readme"},
+		testEntry{&tar.Header{Name: "DIR/other", Typeflag: tar.TypeReg, Mode: 0644}, "other"},
+		testEntry{&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0700}, ""},
+		testEntry{&tar.Header{Name: "Straße", Typeflag: tar.TypeReg, Mode: 0644}, "strasse"},
+	)), WithCaseInsensitive())
+	require.NoError(err)
+
+	for name, content := range map[string]string{
+		"Dir/ReadMe.TXT": "readme",
+		"dir/readme.txt": "readme",
+		"DIR/README.TXT": "readme",
+		"dir/OTHER":      "other",
+		"STRASSE":        "strasse",
+	} {
+		b, err := fs.ReadFile(tfs, name)
+		if assert.NoErrorf(err, "ReadFile(%s)", name) {
+			assert.Equalf(content, string(b), "ReadFile(%s)", name)
+		}
+	}
+
+	entries, err := fs.ReadDir(tfs, "dir")
+	require.NoError(err)
+	require.Len(entries, 2)
+	assert.Equal("ReadMe.TXT", entries[0].Name())
+	assert.Equal("other", entries[1].Name())
+
+	info, err := fs.Stat(tfs, "DIR")
+	require.NoError(err)
+	assert.Equal("Dir", info.Name())
+	assert.Equal(fs.FileMode(0755), info.Mode().Perm())
+
+	_, err = fs.Stat(tfs, "dir/missing")
+	assert.ErrorIs(err, fs.ErrNotExist)
+
+	sub, err := fs.Sub(tfs, "DIR")
+	require.NoError(err)
+	b, err := fs.ReadFile(sub, "README.txt")
+	require.NoError(err)
+	assert.Equal("readme", string(b))
+
+	require.NoError(fstest.TestFS(tfs, "Dir", "Dir/ReadMe.TXT", "Dir/other", "Straße"))
+}
+
+func TestWithUnicodeNormalization(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	const (
+		nfc = "R\u00e9sum\u00e9"
+		nfd = "Re\u0301sume\u0301"
+	)
+
+	for _, form := range []norm.Form{norm.NFC, norm.NFD} {
+		tfs, err := New(bytes.NewReader(newTestArchive(t,
+			testEntry{&tar.Header{Name: nfd + "/" + nfd + ".txt", Typeflag: tar.TypeReg, Mode: 0644}, "resume"},
+		)), WithUnicodeNormalization(form))
+		require.NoError(err)
+
+		for _, name := range []string{nfc + "/" + nfc + ".txt", nfd + "/" + nfc + ".txt", nfc + "/" + nfd + ".txt"} {
+			b, err := fs.ReadFile(tfs, name)
+			if assert.NoErrorf(err, "ReadFile(%q)", name) {
+				assert.Equal("resume", string(b))
+			}
+		}
+
+		_, err = fs.Stat(tfs, "resume/resume.txt")
+		assert.ErrorIs(err, fs.ErrNotExist)
+
+		entries, err := fs.ReadDir(tfs, nfc)
+		require.NoError(err)
+		require.Len(entries, 1)
+		assert.Equal(nfd+".txt", entries[0].Name())
+	}
+
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "R\u00c9SUM\u00c9", Typeflag: tar.TypeReg, Mode: 0644}, "resume"},
+	)), WithUnicodeNormalization(norm.NFC), WithCaseInsensitive())
+	require.NoError(err)
+
+	_, err = fs.Stat(tfs, nfd)
+	assert.NoError(err)
+}
+
+func TestNameCollision(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []testEntry
+		opts    []Option
+	}{
+		{
+			name: "case",
+			entries: []testEntry{
+				{&tar.Header{Name: "README", Typeflag: tar.TypeReg}, "a"},
+				{&tar.Header{Name: "readme", Typeflag: tar.TypeReg}, "b"},
+			},
+			opts: []Option{WithCaseInsensitive()},
+		},
+		{
+			name: "file and directory",
+			entries: []testEntry{
+				{&tar.Header{Name: "dir/file", Typeflag: tar.TypeReg}, "a"},
+				{&tar.Header{Name: "DIR", Typeflag: tar.TypeReg}, "b"},
+			},
+			opts: []Option{WithCaseInsensitive()},
+		},
+		{
+			name: "normalization",
+			entries: []testEntry{
+				{&tar.Header{Name: "caf\u00e9", Typeflag: tar.TypeReg}, "a"},
+				{&tar.Header{Name: "cafe\u0301", Typeflag: tar.TypeReg}, "b"},
+			},
+			opts: []Option{WithUnicodeNormalization(norm.NFC)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(bytes.NewReader(newTestArchive(t, tc.entries...)), tc.opts...)
+			require.ErrorIs(t, err, ErrNameCollision)
+
+			_, err = New(bytes.NewReader(newTestArchive(t, tc.entries...)))
+			require.NoError(t, err)
+		})
+	}
+}
+
+type testEntry struct {
+	header  *tar.Header
+	content string
+}
+
+// newTestArchive returns a tar archive containing entries.
+func newTestArchive(t *testing.T, entries ...testEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		h := *e.header
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(e.content))
+		}
+		require.NoError(t, tw.WriteHeader(&h))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func TestNewContext(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644}, "a"},
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "b"},
+		testEntry{&tar.Header{Name: "c", Typeflag: tar.TypeReg, Mode: 0644}, "c"},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tfs, err := NewContext(ctx, bytes.NewReader(archive))
+	require.NoError(err)
+	require.NoError(fstest.TestFS(tfs, "a", "b", "c"))
+
+	var entries int
+	_, err = NewContext(ctx, bytes.NewReader(archive), WithProgress(func(p Progress) {
+		entries = p.Entries
+		if p.Entries == 2 {
+			cancel()
+		}
+	}))
+	assert.ErrorIs(err, context.Canceled)
+	assert.Equal(2, entries)
+
+	_, err = NewContext(ctx, bytes.NewReader(archive))
+	assert.ErrorIs(err, context.Canceled)
+}
+
+func TestNewContextReader(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("a", 10000)},
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "b"},
+	)
+
+	// r is not an io.ReaderAt, it is read in memory
+	var progress []Progress
+	r := iotest.HalfReader(bytes.NewReader(archive))
+	_, err := New(r, WithProgress(func(p Progress) {
+		progress = append(progress, p)
+	}))
+	require.NoError(err)
+
+	require.NotEmpty(progress)
+	for i, p := range progress[1:] {
+		assert.GreaterOrEqual(p.Buffered, progress[i].Buffered)
+	}
+	last := progress[len(progress)-1]
+	assert.Equal(Progress{Entries: 2, Bytes: 11264, Buffered: int64(len(archive))}, last)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	br := bytes.NewReader(archive)
+	_, err = NewContext(ctx, iotest.HalfReader(br), WithProgress(func(p Progress) {
+		assert.Zero(p.Entries)
+		if p.Buffered >= 1024 {
+			cancel()
+		}
+	}))
+	assert.ErrorIs(err, context.Canceled)
+	assert.NotZero(br.Len(), "the reader is not read after ctx is done")
+}
+
+func TestWithProgress(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		testEntry{&tar.Header{Name: "dir/a", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("a", 600)},
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "b"},
+	)
+
+	var progress []Progress
+	_, err := New(bytes.NewReader(archive), WithProgress(func(p Progress) {
+		progress = append(progress, p)
+	}))
+	require.NoError(err)
+
+	assert.Equal([]Progress{{Entries: 1, Bytes: 512}, {Entries: 2, Bytes: 1024}, {Entries: 3, Bytes: 2560}}, progress)
+}
+
+func TestLateDirectoryHeaders(t *testing.T) {
+	modTime := time.Unix(1700000000, 0)
+	headers := []testEntry{
+		{&tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0700, ModTime: modTime}, ""},
+		{&tar.Header{Name: "a/b/", Typeflag: tar.TypeDir, Mode: 0750, ModTime: modTime.Add(time.Hour)}, ""},
+		{&tar.Header{Name: "a/b/file", Typeflag: tar.TypeReg, Mode: 0644}, "file"},
+		{&tar.Header{Name: "a/c", Typeflag: tar.TypeReg, Mode: 0644}, "c"},
+	}
+
+	for _, order := range permutations(len(headers)) {
+		entries := make([]testEntry, len(order))
+		for i, j := range order {
+			entries[i] = headers[j]
+		}
+
+		t.Run(fmt.Sprint(order), func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			tfs, err := New(bytes.NewReader(newTestArchive(t, entries...)))
+			require.NoError(err)
+
+			require.NoError(fstest.TestFS(tfs, "a", "a/b", "a/b/file", "a/c"))
+
+			for name, want := range map[string]*tar.Header{"a": headers[0].header, "a/b": headers[1].header} {
+				info, err := fs.Stat(tfs, name)
+				require.NoError(err)
+				assert.Equal(fs.ModeDir|fs.FileMode(want.Mode), info.Mode(), name)
+				assert.True(want.ModTime.Equal(info.ModTime()), name)
+				if assert.IsType(&tar.Header{}, info.Sys(), name) {
+					assert.Equal(want.Name, info.Sys().(*tar.Header).Name, name)
+				}
+			}
+
+			dirEntries, err := fs.ReadDir(tfs, "a")
+			require.NoError(err)
+			require.Len(dirEntries, 2)
+			assert.Equal("b", dirEntries[0].Name())
+			assert.Equal("c", dirEntries[1].Name())
+
+			var names, archiveOrder []string
+			for e, err := range Entries(tfs) {
+				require.NoError(err)
+				names = append(names, e.Name)
+			}
+			for _, e := range entries {
+				archiveOrder = append(archiveOrder, path.Clean(e.header.Name))
+			}
+			assert.Equal(archiveOrder, names)
+		})
+
+		t.Run(fmt.Sprint(order, "/case insensitive"), func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			for i := range entries {
+				if entries[i].header.Typeflag == tar.TypeDir {
+					h := *entries[i].header
+					h.Name = strings.ToUpper(h.Name)
+					entries[i].header = &h
+				}
+			}
+
+			tfs, err := New(bytes.NewReader(newTestArchive(t, entries...)), WithCaseInsensitive())
+			require.NoError(err)
+
+			info, err := fs.Stat(tfs, "a/b")
+			require.NoError(err)
+			assert.Equal(fs.ModeDir|0750, info.Mode())
+
+			info, err = fs.Stat(tfs, "a")
+			require.NoError(err)
+			assert.Equal(fs.ModeDir|0700, info.Mode())
+
+			dirEntries, err := fs.ReadDir(tfs, "a")
+			require.NoError(err)
+			assert.Len(dirEntries, 2)
+
+			b, err := fs.ReadFile(tfs, "A/B/FILE")
+			require.NoError(err)
+			assert.Equal("file", string(b))
+		})
+	}
+}
+
+// permutations returns all the permutations of 0..n-1.
+func permutations(n int) [][]int {
+	if n == 0 {
+		return [][]int{{}}
+	}
+
+	var perms [][]int
+	for _, p := range permutations(n - 1) {
+		for i := 0; i <= len(p); i++ {
+			perm := slices.Insert(slices.Clone(p), i, n-1)
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+func TestTypeConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []testEntry
+		files   map[string]string
+		dirs    []string
+		missing []string
+	}{
+		{
+			name: "file then child",
+			entries: []testEntry{
+				{&tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0644}, "foo"},
+				{&tar.Header{Name: "foo/bar", Typeflag: tar.TypeReg, Mode: 0644}, "bar"},
+			},
+			files: map[string]string{"foo/bar": "bar"},
+			dirs:  []string{"foo"},
+		},
+		{
+			name: "directory then file",
+			entries: []testEntry{
+				{&tar.Header{Name: "foo/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+				{&tar.Header{Name: "foo/bar/baz", Typeflag: tar.TypeReg, Mode: 0644}, "baz"},
+				{&tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0644}, "foo"},
+			},
+			files:   map[string]string{"foo": "foo"},
+			missing: []string{"foo/bar", "foo/bar/baz"},
+		},
+		{
+			name: "directory then file then child",
+			entries: []testEntry{
+				{&tar.Header{Name: "foo/bar", Typeflag: tar.TypeReg, Mode: 0644}, "bar"},
+				{&tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0644}, "foo"},
+				{&tar.Header{Name: "foo/baz", Typeflag: tar.TypeReg, Mode: 0644}, "baz"},
+			},
+			files:   map[string]string{"foo/baz": "baz"},
+			dirs:    []string{"foo"},
+			missing: []string{"foo/bar"},
+		},
+		{
+			name: "symlink then child",
+			entries: []testEntry{
+				{&tar.Header{Name: "foo", Typeflag: tar.TypeSymlink, Linkname: "bar"}, ""},
+				{&tar.Header{Name: "foo/bar", Typeflag: tar.TypeReg, Mode: 0644}, "bar"},
+			},
+			files: map[string]string{"foo/bar": "bar"},
+			dirs:  []string{"foo"},
+		},
+		{
+			name: "directory then symlink",
+			entries: []testEntry{
+				{&tar.Header{Name: "foo/bar", Typeflag: tar.TypeReg, Mode: 0644}, "bar"},
+				{&tar.Header{Name: "foo", Typeflag: tar.TypeSymlink, Linkname: "bar"}, ""},
+			},
+			missing: []string{"foo/bar"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			tfs, err := New(bytes.NewReader(newTestArchive(t, tc.entries...)))
+			require.NoError(err)
+
+			for name, content := range tc.files {
+				b, err := fs.ReadFile(tfs, name)
+				if assert.NoErrorf(err, "ReadFile(%s)", name) {
+					assert.Equalf(content, string(b), "ReadFile(%s)", name)
+				}
+			}
+
+			for _, name := range tc.dirs {
+				info, err := fs.Stat(tfs, name)
+				if assert.NoErrorf(err, "Stat(%s)", name) {
+					assert.Truef(info.IsDir(), "Stat(%s).IsDir()", name)
+					assert.Nilf(info.Sys(), "Stat(%s).Sys()", name)
+				}
+			}
+
+			for _, name := range tc.missing {
+				_, err := fs.Stat(tfs, name)
+				assert.ErrorIsf(err, fs.ErrNotExist, "Stat(%s)", name)
+			}
+
+			var names []string
+			for e, err := range Entries(tfs) {
+				require.NoError(err)
+				names = append(names, e.Name)
+			}
+			for _, name := range tc.missing {
+				assert.NotContains(names, name)
+			}
+		})
+	}
+}
+
+// FuzzNew checks that conflicting entries replace each other, the last one winning.
+// Each pair of bytes of data is an entry, the first byte giving its type and the second its name.
+func FuzzNew(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 1})
+	f.Add([]byte{1, 0, 0, 1, 0, 0})
+	f.Add([]byte{0, 5, 1, 1, 2, 1, 0, 0})
+	f.Add([]byte{2, 0, 1, 4, 0, 8, 2, 4})
+
+	types := []struct {
+		typeflag byte
+		mode     fs.FileMode
+	}{
+		{tar.TypeReg, 0},
+		{tar.TypeDir, fs.ModeDir},
+		{tar.TypeSymlink, fs.ModeSymlink},
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		type node struct {
+			mode   fs.FileMode
+			header bool
+		}
+		want := make(map[string]node)
+
+		var entries []testEntry
+		for i := 0; i+1 < len(data); i += 2 {
+			typ := types[int(data[i])%len(types)]
+
+			// 1 to 3 segments, each being a or b
+			segments := make([]string, 1+int(data[i+1])%3)
+			for j := range segments {
+				segments[j] = string(rune('a' + data[i+1]>>(2+j)&1))
+			}
+			name := strings.Join(segments, "/")
+
+			entries = append(entries, testEntry{&tar.Header{Name: name, Typeflag: typ.typeflag, Mode: 0755, Linkname: "a"}, ""})
+
+			for j := 1; j < len(segments); j++ {
+				parent := strings.Join(segments[:j], "/")
+				if n, ok := want[parent]; !ok || n.mode != fs.ModeDir {
+					want[parent] = node{fs.ModeDir, false}
+				}
+			}
+			if typ.mode != fs.ModeDir {
+				for other := range want {
+					if strings.HasPrefix(other, name+"/") {
+						delete(want, other)
+					}
+				}
+			}
+			want[name] = node{typ.mode, true}
+		}
+
+		tfs, err := New(bytes.NewReader(newTestArchive(t, entries...)))
+		require.NoError(t, err)
+
+		got := make(map[string]fs.FileMode)
+		require.NoError(t, fs.WalkDir(tfs, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || name == "." {
+				return err
+			}
+			got[name] = d.Type()
+			return nil
+		}))
+
+		wantModes := make(map[string]fs.FileMode)
+		var wantHeaders []string
+		for name, n := range want {
+			wantModes[name] = n.mode
+			if n.header {
+				wantHeaders = append(wantHeaders, name)
+			}
+		}
+		require.Equal(t, wantModes, got)
+
+		var headers []string
+		for e, err := range Entries(tfs) {
+			require.NoError(t, err)
+			headers = append(headers, e.Name)
+		}
+		require.ElementsMatch(t, wantHeaders, headers)
+
+		require.NoError(t, fstest.TestFS(tfs, slices.Collect(maps.Keys(want))...))
+	})
+}
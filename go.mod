@@ -1,11 +1,14 @@
 module github.com/nlepage/go-tarfs
 
-go 1.17
+go 1.25.0
 
-require github.com/stretchr/testify v1.7.1
+require (
+	github.com/stretchr/testify v1.11.1
+	golang.org/x/text v0.41.0
+)
 
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
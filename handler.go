@@ -0,0 +1,290 @@
+package tarfs
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// HandlerOptions configures Handler.
+type HandlerOptions struct {
+	// Precompressed serves the .br or .gz sibling of a file, if the client accepts it.
+	Precompressed bool
+
+	// Fallback is the name of the file served for the paths which do not exist,
+	// such as "index.html" for single page applications.
+	Fallback string
+
+	// DisableListing disables the listing of the directories which have no index.html.
+	DisableListing bool
+}
+
+// Handler returns an http.Handler serving the contents of fsys.
+// Unlike http.FileServer, files of an fs.FS created by this package have a strong ETag
+// derived from their position in the archive, size and modification time.
+// Conditional and range requests are supported.
+func Handler(fsys fs.FS, opts *HandlerOptions) http.Handler {
+	if opts == nil {
+		opts = &HandlerOptions{}
+	}
+	return &handler{fsys, opts}
+}
+
+type handler struct {
+	fsys fs.FS
+	opts *HandlerOptions
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	name := strings.TrimPrefix(urlPath, "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		if h.opts.Fallback != "" {
+			if fi, err := fs.Stat(h.fsys, h.opts.Fallback); err == nil && fi.Mode().IsRegular() {
+				h.serveFile(w, r, h.opts.Fallback, fi)
+				return
+			}
+		}
+		h.error(w, err)
+		return
+	}
+
+	if !info.IsDir() {
+		h.serveFile(w, r, name, info)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		localRedirect(w, r)
+		return
+	}
+
+	index := path.Join(name, "index.html")
+	if fi, err := fs.Stat(h.fsys, index); err == nil && fi.Mode().IsRegular() {
+		h.serveFile(w, r, index, fi)
+		return
+	}
+
+	if h.opts.DisableListing {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	h.serveDir(w, r, name, info)
+}
+
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	if !info.Mode().IsRegular() {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	contentName := name
+
+	if h.opts.Precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		for _, enc := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if !acceptsEncoding(r, enc.name) {
+				continue
+			}
+			fi, err := fs.Stat(h.fsys, name+enc.ext)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			if w.Header().Get("Content-Type") == "" {
+				w.Header().Set("Content-Type", "application/octet-stream")
+			}
+			w.Header().Set("Content-Encoding", enc.name)
+			contentName, info = name+enc.ext, fi
+			break
+		}
+	}
+
+	f, err := h.fsys.Open(contentName)
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+	defer f.Close()
+
+	if tag := etag(f, info); tag != "" {
+		w.Header().Set("Etag", tag)
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			h.error(w, err)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+func (h *handler) serveDir(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	if !info.ModTime().IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	type item struct {
+		Name    string
+		URL     string
+		Size    string
+		ModTime string
+	}
+
+	items := make([]item, 0, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			h.error(w, err)
+			return
+		}
+
+		it := item{Name: e.Name(), URL: (&url.URL{Path: e.Name()}).String()}
+		if e.IsDir() {
+			it.Name += "/"
+			it.URL += "/"
+		} else {
+			it.Size = strconv.FormatInt(fi.Size(), 10)
+		}
+		if !fi.ModTime().IsZero() {
+			it.ModTime = fi.ModTime().UTC().Format("2006-01-02 15:04:05")
+		}
+
+		items = append(items, it)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	dirListing.Execute(w, struct {
+		Path  string
+		Items []item
+	}{"/" + strings.TrimPrefix(name, "."), items})
+}
+
+// localRedirect redirects the request of a directory to its name followed by a slash, like http.FileServer.
+// The target is relative, and built from the path requested by the client,
+// which is not r.URL.Path behind http.StripPrefix.
+func localRedirect(w http.ResponseWriter, r *http.Request) {
+	reqPath := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		reqPath = u.Path
+	}
+
+	target := path.Base(reqPath) + "/"
+	if q := r.URL.RawQuery; q != "" {
+		target += "?" + q
+	}
+
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+func (h *handler) error(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// etag returns a strong ETag for a file of this package, derived from its position in the archive,
+// size and modification time, or an empty string for other files.
+func etag(f fs.File, info fs.FileInfo) string {
+	tf, ok := f.(*file)
+	if !ok {
+		return ""
+	}
+
+	e, ok := tf.entry.(*regEntry)
+	if !ok {
+		return ""
+	}
+
+	return `"` + strconv.FormatInt(e.offset, 36) + "-" + strconv.FormatInt(info.Size(), 36) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 36) + `"`
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of r accepts the content coding enc.
+func acceptsEncoding(r *http.Request, enc string) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			if strings.TrimSpace(coding) != enc {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var dirListing = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 1em; text-align: left; }
+td.size { text-align: right; }
+tr:nth-child(even) { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{range .Items}}<tr><td><a href="{{.URL}}">{{.Name}}</a></td><td class="size">{{.Size}}</td><td>{{.ModTime}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
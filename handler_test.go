@@ -0,0 +1,152 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, srv *httptest.Server, path string, header http.Header) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := srv.Client().Transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return res, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	srv := httptest.NewServer(Handler(tfs, nil))
+	defer srv.Close()
+
+	res, body := get(t, srv, "/dir1/dir11/file111", nil)
+	require.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("file111", body)
+	assert.NotEmpty(res.Header.Get("Last-Modified"))
+
+	etag := res.Header.Get("Etag")
+	require.Regexp(`^"[^"]+"$`, etag)
+
+	res, _ = get(t, srv, "/dir1/dir11/file111", http.Header{"If-None-Match": {etag}})
+	assert.Equal(http.StatusNotModified, res.StatusCode)
+
+	res, _ = get(t, srv, "/dir1/file12", http.Header{"If-None-Match": {etag}})
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	res, body = get(t, srv, "/dir1/file11", http.Header{"Range": {"bytes=2-4"}})
+	assert.Equal(http.StatusPartialContent, res.StatusCode)
+	assert.Equal("le1", body)
+
+	res, _ = get(t, srv, "/baz", nil)
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+
+	res, _ = get(t, srv, "/dir1", nil)
+	assert.Equal(http.StatusMovedPermanently, res.StatusCode)
+	assert.Equal("dir1/", res.Header.Get("Location"))
+
+	res, body = get(t, srv, "/dir1/", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Contains(body, `<a href="dir11/">dir11/</a>`)
+	assert.Contains(body, `<a href="file11">file11</a>`)
+}
+
+func TestHandlerStripPrefix(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	srv := httptest.NewServer(http.StripPrefix("/static", Handler(tfs, nil)))
+	defer srv.Close()
+
+	for _, tc := range []struct{ path, location string }{
+		{"/static", "/static/"},
+		{"/static/dir1", "/static/dir1/"},
+		{"/static/dir1/dir11?q=1", "/static/dir1/dir11/?q=1"},
+	} {
+		res, _ := get(t, srv, tc.path, nil)
+		require.Equal(http.StatusMovedPermanently, res.StatusCode, tc.path)
+
+		base, err := url.Parse(srv.URL + tc.path)
+		require.NoError(err)
+		location, err := base.Parse(res.Header.Get("Location"))
+		require.NoError(err)
+		require.Equal(tc.location, location.RequestURI(), tc.path)
+	}
+
+	res, body := get(t, srv, "/static/dir1/", nil)
+	require.Equal(http.StatusOK, res.StatusCode)
+	require.Contains(body, `<a href="file11">file11</a>`)
+}
+
+func TestHandlerOptions(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "index.html", Typeflag: tar.TypeReg, Mode: 0644}, "index"},
+		testEntry{&tar.Header{Name: "app.js", Typeflag: tar.TypeReg, Mode: 0644}, "app"},
+		testEntry{&tar.Header{Name: "app.js.gz", Typeflag: tar.TypeReg, Mode: 0644}, "gzip app"},
+		testEntry{&tar.Header{Name: "app.js.br", Typeflag: tar.TypeReg, Mode: 0644}, "brotli app"},
+		testEntry{&tar.Header{Name: "static/style.css", Typeflag: tar.TypeReg, Mode: 0644}, "style"},
+	)))
+	require.NoError(err)
+
+	srv := httptest.NewServer(Handler(tfs, &HandlerOptions{
+		Precompressed:  true,
+		Fallback:       "index.html",
+		DisableListing: true,
+	}))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		acceptEncoding string
+		body           string
+		encoding       string
+	}{
+		{"identity", "app", ""},
+		{"gzip, deflate", "gzip app", "gzip"},
+		{"gzip, br", "brotli app", "br"},
+		{"gzip, br;q=0", "gzip app", "gzip"},
+	} {
+		res, body := get(t, srv, "/app.js", http.Header{"Accept-Encoding": {test.acceptEncoding}})
+		assert.Equal(test.body, body, "with Accept-Encoding: %s", test.acceptEncoding)
+		assert.Equal(test.encoding, res.Header.Get("Content-Encoding"), "with Accept-Encoding: %s", test.acceptEncoding)
+		assert.Equal("text/javascript; charset=utf-8", res.Header.Get("Content-Type"), "with Accept-Encoding: %s", test.acceptEncoding)
+		assert.Equal("Accept-Encoding", res.Header.Get("Vary"))
+	}
+
+	res, body := get(t, srv, "/", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("index", body)
+
+	res, body = get(t, srv, "/some/route", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("index", body)
+
+	res, _ = get(t, srv, "/static/", nil)
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+}
@@ -0,0 +1,21 @@
+package tarfs
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/nlepage/go-tarfs/httprange"
+)
+
+// NewHTTP creates a new tar fs.FS from the archive at url, read with HTTP Range requests by client,
+// or http.DefaultClient if client is nil.
+// The archive is not downloaded, the server must support Range requests.
+// See httprange.NewReaderAt.
+func NewHTTP(url string, client *http.Client, opts ...Option) (FS, error) {
+	ra, err := httprange.NewReaderAt(url, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(io.NewSectionReader(ra, 0, ra.Size()), opts...)
+}
@@ -0,0 +1,27 @@
+package tarfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTP(t *testing.T) {
+	require := require.New(t)
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(".")))
+	defer srv.Close()
+
+	tfs, err := NewHTTP(srv.URL+"/test.tar", srv.Client())
+	require.NoError(err)
+	defer tfs.Close()
+
+	err = fstest.TestFS(tfs, "bar", "foo", "dir1", "dir1/dir11", "dir1/dir11/file111", "dir1/file11", "dir1/file12", "dir2", "dir2/dir21", "dir2/dir21/file211", "dir2/dir21/file212")
+	require.NoError(err)
+
+	_, err = NewHTTP(srv.URL+"/missing.tar", srv.Client())
+	require.Error(err)
+}
@@ -0,0 +1,255 @@
+// Package httprange reads remote files with HTTP Range requests.
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	retries   = 3                      // Number of retries of a failed request
+	backoff   = 100 * time.Millisecond // Delay before the first retry, doubled for each retry
+	chunkSize = 32 << 10               // Minimum size of a Range request
+)
+
+// ErrChanged is returned when the remote file changed since its size was discovered.
+var ErrChanged = errors.New("remote file changed")
+
+// ReaderAt is an io.ReaderAt reading a remote file with HTTP Range requests.
+// Reads smaller than 32KiB are served from the last chunk requested,
+// so that reading tar headers does not issue a request per header.
+// If the server ignores Range requests, the whole file is downloaded once and held in memory.
+// The Content-Range of partial responses is checked to be the range requested.
+type ReaderAt struct {
+	url    string
+	client *http.Client
+	size   int64
+	etag   string
+
+	mu       sync.Mutex
+	chunk    []byte
+	chunkOff int64
+
+	// data is the whole file, if the server ignores Range requests
+	data atomic.Pointer[[]byte]
+}
+
+var _ io.ReaderAt = &ReaderAt{}
+
+// NewReaderAt returns a ReaderAt reading the file at url with client,
+// or http.DefaultClient if client is nil.
+// The size and ETag of the file are discovered with a HEAD request.
+// If the file has an ETag, ReaderAt returns ErrChanged when reading a different version of the file.
+// Strong ETags are checked by the server with If-Match, weak ETags are compared with the ones of the responses.
+func NewReaderAt(url string, client *http.Client) (*ReaderAt, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	r := &ReaderAt{url: url, client: client}
+
+	resp, err := r.do(http.MethodHead, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, r.statusErr(resp)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("%s: unknown size", url)
+	}
+
+	r.size = resp.ContentLength
+	r.etag = resp.Header.Get("ETag")
+
+	return r, nil
+}
+
+// Size returns the size of the remote file.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of the remote file starting at off.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%s: negative offset", r.url)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), r.size)
+
+	if data := r.data.Load(); data != nil {
+		n := copy(p, (*data)[off:end])
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+
+	if end-off >= chunkSize {
+		n, err := r.readRange(p[:end-off], off)
+		if err == nil && end-off < int64(len(p)) {
+			err = io.EOF
+		}
+		return n, err
+	}
+
+	r.mu.Lock()
+	chunk, chunkOff := r.chunk, r.chunkOff
+	r.mu.Unlock()
+
+	if off < chunkOff || end > chunkOff+int64(len(chunk)) {
+		// The chunk is requested without holding mu, so that concurrent reads are not serialized
+		chunk, chunkOff = make([]byte, min(chunkSize, r.size-off)), off
+		if _, err := r.readRange(chunk, off); err != nil {
+			return 0, err
+		}
+
+		r.mu.Lock()
+		r.chunk, r.chunkOff = chunk, chunkOff
+		r.mu.Unlock()
+	}
+
+	n := copy(p, chunk[off-chunkOff:end-chunkOff])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readRange reads exactly len(p) bytes at off, retrying on failure.
+func (r *ReaderAt) readRange(p []byte, off int64) (int, error) {
+	var err error
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			time.Sleep(backoff << (i - 1))
+		}
+
+		var retry bool
+		if retry, err = r.tryReadRange(p, off); err == nil || !retry {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// tryReadRange reads exactly len(p) bytes at off, it reports whether a failure may be retried.
+func (r *ReaderAt) tryReadRange(p []byte, off int64) (bool, error) {
+	header := http.Header{"Range": {"bytes=" + strconv.FormatInt(off, 10) + "-" + strconv.FormatInt(off+int64(len(p))-1, 10)}}
+	if r.etag != "" && !strings.HasPrefix(r.etag, "W/") {
+		// If-Match uses the strong comparison, which always fails with weak ETags
+		header.Set("If-Match", r.etag)
+	}
+
+	resp, err := r.do(http.MethodGet, header)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return false, fmt.Errorf("%s: %w", r.url, ErrChanged)
+	case resp.StatusCode >= 500:
+		return true, r.statusErr(resp)
+	case resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK:
+		return false, r.statusErr(resp)
+	}
+
+	if etag := resp.Header.Get("ETag"); r.etag != "" && etag != "" && strings.TrimPrefix(etag, "W/") != strings.TrimPrefix(r.etag, "W/") {
+		return false, fmt.Errorf("%s: %w", r.url, ErrChanged)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		// The server ignored the Range header and sends the whole file,
+		// which is kept instead of being downloaded again for each read
+		if resp.ContentLength >= 0 && resp.ContentLength != r.size {
+			return false, fmt.Errorf("%s: %w", r.url, ErrChanged)
+		}
+		data := make([]byte, r.size)
+		if _, err := io.ReadFull(resp.Body, data); err != nil {
+			return true, err
+		}
+		r.data.Store(&data)
+		copy(p, data[off:])
+		return false, nil
+	}
+
+	if err := r.checkContentRange(resp, off, int64(len(p))); err != nil {
+		return false, err
+	}
+
+	if _, err := io.ReadFull(resp.Body, p); err != nil {
+		return true, err
+	}
+
+	return false, nil
+}
+
+// checkContentRange checks that the Content-Range of the partial response resp is the range of n bytes at off,
+// so that the data of another range sent by a proxy is not read instead.
+func (r *ReaderAt) checkContentRange(resp *http.Response, off, n int64) error {
+	contentRange := resp.Header.Get("Content-Range")
+	errRange := fmt.Errorf("%s: unexpected Content-Range %q for bytes %d-%d", r.url, contentRange, off, off+n-1)
+
+	rng, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return errRange
+	}
+	rng, size, ok := strings.Cut(rng, "/")
+	if !ok {
+		return errRange
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return errRange
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start != off {
+		return errRange
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end != off+n-1 {
+		return errRange
+	}
+
+	// The complete length may be unknown
+	if size != "*" && size != strconv.FormatInt(r.size, 10) {
+		return fmt.Errorf("%s: %w", r.url, ErrChanged)
+	}
+
+	return nil
+}
+
+func (r *ReaderAt) do(method string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	return r.client.Do(req)
+}
+
+func (r *ReaderAt) statusErr(resp *http.Response) error {
+	return fmt.Errorf("%s: unexpected status %s", r.url, resp.Status)
+}
@@ -0,0 +1,276 @@
+package httprange
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer returns a server of data, having ETag etag if not empty.
+func newServer(t *testing.T, data *[]byte, etag *string, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			requests.Add(1)
+		}
+		if *etag != "" {
+			w.Header().Set("ETag", *etag)
+		}
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(*data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestReadAt(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	data, etag := newData(100<<10), `"v1"`
+	var requests atomic.Int32
+	srv := newServer(t, &data, &etag, &requests)
+
+	r, err := NewReaderAt(srv.URL, nil)
+	require.NoError(err)
+	assert.Equal(int64(len(data)), r.Size())
+
+	for _, tc := range []struct {
+		off, size int
+		err       error
+	}{
+		{0, 512, nil},
+		{512, 512, nil},
+		{1000, 50 << 10, nil},
+		{len(data) - 100, 512, io.EOF},
+		{len(data) - (40 << 10), 50 << 10, io.EOF},
+		{len(data), 512, io.EOF},
+	} {
+		t.Run(strconv.Itoa(tc.off), func(t *testing.T) {
+			p := make([]byte, tc.size)
+			n, err := r.ReadAt(p, int64(tc.off))
+			assert.Equal(tc.err, err)
+			end := min(tc.off+tc.size, len(data))
+			assert.Equal(end-tc.off, n)
+			assert.Equal(data[tc.off:end], p[:n])
+		})
+	}
+
+	// HEAD, first chunk, 50KiB read, last chunk and 40KiB read
+	assert.Equal(int32(5), requests.Load())
+}
+
+func TestReadAtConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	data := newData(200 << 10)
+
+	// The request of the first chunk waits for the request of another chunk
+	first, other := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Range") {
+		case "":
+		case "bytes=0-32767":
+			close(first)
+			select {
+			case <-other:
+			case <-time.After(2 * time.Second):
+				http.Error(w, "timeout", http.StatusBadRequest)
+				return
+			}
+		default:
+			close(other)
+		}
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	r, err := NewReaderAt(srv.URL, nil)
+	require.NoError(err)
+
+	read := func(off int64) error {
+		p := make([]byte, 512)
+		if _, err := r.ReadAt(p, off); err != nil {
+			return err
+		}
+		if !bytes.Equal(data[off:off+512], p) {
+			return errors.New("unexpected content")
+		}
+		return nil
+	}
+
+	done := make(chan error)
+	go func() { done <- read(0) }()
+
+	<-first
+	require.NoError(read(100 << 10))
+	require.NoError(<-done)
+}
+
+func TestReadAtRetry(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	data := newData(1 << 10)
+	var failures atomic.Int32
+	failures.Store(2)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	r, err := NewReaderAt(srv.URL, srv.Client())
+	require.NoError(err)
+
+	p := make([]byte, 100)
+	_, err = r.ReadAt(p, 10)
+	require.NoError(err)
+	assert.Equal(data[10:110], p)
+
+	failures.Store(retries + 1)
+	_, err = r.ReadAt(p, 0)
+	assert.ErrorContains(err, "503")
+}
+
+func TestReadAtChanged(t *testing.T) {
+	require := require.New(t)
+
+	data, etag := newData(1<<10), `"v1"`
+	srv := newServer(t, &data, &etag, nil)
+
+	r, err := NewReaderAt(srv.URL, nil)
+	require.NoError(err)
+
+	data, etag = newData(2<<10), `"v2"`
+
+	_, err = r.ReadAt(make([]byte, 100), 0)
+	require.ErrorIs(err, ErrChanged)
+}
+
+func TestReadAtWeakETag(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	data, etag := newData(1<<10), `W/"v1"`
+	srv := newServer(t, &data, &etag, nil)
+
+	r, err := NewReaderAt(srv.URL, nil)
+	require.NoError(err)
+
+	p := make([]byte, 100)
+	_, err = r.ReadAt(p, 10)
+	require.NoError(err)
+	assert.Equal(data[10:110], p)
+
+	data, etag = newData(2<<10), `W/"v2"`
+
+	_, err = r.ReadAt(make([]byte, 100), 0)
+	require.ErrorIs(err, ErrChanged)
+}
+
+func TestReadAtContentRange(t *testing.T) {
+	data := newData(100 << 10)
+
+	for _, tc := range []struct {
+		contentRange string
+		err          string
+	}{
+		{"bytes 0-32767/102400", ""},
+		{"bytes 0-32767/*", ""},
+		{"bytes 1-32768/102400", "unexpected Content-Range"},
+		{"bytes 0-99/102400", "unexpected Content-Range"},
+		{"", "unexpected Content-Range"},
+		{"bytes 0-32767/1000", ErrChanged.Error()},
+	} {
+		t.Run(tc.contentRange, func(t *testing.T) {
+			require := require.New(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+					return
+				}
+				w.Header().Set("Content-Range", tc.contentRange)
+				w.Header().Set("Content-Length", strconv.Itoa(chunkSize))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(data[:chunkSize])
+			}))
+			defer srv.Close()
+
+			r, err := NewReaderAt(srv.URL, nil)
+			require.NoError(err)
+
+			p := make([]byte, chunkSize)
+			_, err = r.ReadAt(p, 0)
+			if tc.err != "" {
+				require.ErrorContains(err, tc.err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(data[:chunkSize], p)
+		})
+	}
+}
+
+func TestReadAtRangeIgnored(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	data := newData(100 << 10)
+	var gets atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			w.Write(data)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := NewReaderAt(srv.URL, nil)
+	require.NoError(err)
+
+	p := make([]byte, 100)
+	_, err = r.ReadAt(p, 500)
+	require.NoError(err)
+	assert.Equal(data[500:600], p)
+
+	// The file is downloaded once
+	for _, off := range []int{50 << 10, 1000, 90 << 10} {
+		p := make([]byte, 40<<10)
+		n, err := r.ReadAt(p, int64(off))
+		end := min(off+len(p), len(data))
+		if end < off+len(p) {
+			assert.Equal(io.EOF, err)
+		} else {
+			assert.NoError(err)
+		}
+		assert.Equal(data[off:end], p[:n])
+	}
+	assert.Equal(int32(1), gets.Load())
+}
+
+func TestNewReaderAtNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewReaderAt(srv.URL, nil)
+	assert.ErrorContains(t, err, "404")
+}
@@ -0,0 +1,542 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// index is a compact index of the entries of an archive, holding millions of entries in little memory.
+// Entries are the nodes of a tree, identified by their position in the packed arrays holding their attributes.
+// Names are stored as interned path segments, and headers are read again from the archive when needed.
+type index struct {
+	ra         io.ReaderAt
+	decodeName func(string) (string, error)
+	// h is the handle of the tarfs, held while headers are read again, see header
+	h *handle
+
+	// segments are the interned segments of names
+	segments []string
+
+	// Attributes of the nodes, the root being node 0
+	parent     []uint32
+	segment    []uint32
+	mode       []fs.FileMode
+	typeflag   []byte // typeflag of the header of the entry, 0 if it has no header
+	size       []int64
+	mtime      []int64 // Unix seconds
+	mtimeNsec  []int32
+	offset     []int64 // offset of the headers of the entry, -1 if it has no header or its offset is unknown
+	dataOffset []int64 // offset of the content of the entry, -1 if it is not contiguous
+
+	// children of node i are children[childStart[i]:childStart[i+1]], sorted by name, see finish
+	children   []uint32
+	childStart []uint32
+
+	// headers are the headers of the entries whose offset is unknown, which follow sparse files
+	headers map[uint32]eagerHeader
+	// nested are the nested archives, see WithNestedArchives
+	nested map[uint32]*nestedEntry
+	// truncated is the node whose content is truncated, or -1, see WithRecovery
+	truncated int64
+
+	// normalize returns the lookup key of a name, it is nil if names are looked up as is
+	normalize func(string) string
+	// keys maps lookup keys to names of entries, if normalize is not nil
+	keys map[string]string
+
+	// segmentIDs and childIDs are used while indexing, and released by finish
+	segmentIDs map[string]uint32
+	childIDs   map[uint64]uint32
+	// escaped maps the escaped names of entries and of their parents to their names in the archive,
+	// it is used while indexing, see checkEscaped
+	escaped map[string]string
+}
+
+// removed is the parent of the nodes removed from the tree, see replace.
+const removed = ^uint32(0)
+
+// eagerHeader is a header kept in memory, because it cannot be read again from the archive.
+type eagerHeader struct {
+	h *tar.Header
+	// offset is the offset of the last block of the header
+	offset int64
+}
+
+func newIndex(ra io.ReaderAt, o *options) *index {
+	idx := &index{
+		ra:         ra,
+		decodeName: o.decodeName,
+		headers:    make(map[uint32]eagerHeader),
+		nested:     make(map[uint32]*nestedEntry),
+		truncated:  -1,
+		normalize:  o.normalizer(),
+		segmentIDs: make(map[string]uint32),
+		childIDs:   make(map[uint64]uint32),
+	}
+	if idx.normalize != nil {
+		idx.keys = make(map[string]string)
+	}
+
+	idx.add(0, ".")
+	idx.mode[0] = fs.ModeDir
+
+	return idx
+}
+
+// add appends a directory node without header named segment to parent.
+func (idx *index) add(parent uint32, segment string) uint32 {
+	seg, ok := idx.segmentIDs[segment]
+	if !ok {
+		seg = uint32(len(idx.segments))
+		idx.segments = append(idx.segments, segment)
+		idx.segmentIDs[segment] = seg
+	}
+
+	id := uint32(len(idx.parent))
+	idx.parent = append(idx.parent, parent)
+	idx.segment = append(idx.segment, seg)
+	idx.mode = append(idx.mode, fs.ModeDir)
+	idx.typeflag = append(idx.typeflag, 0)
+	idx.size = append(idx.size, 0)
+	idx.mtime = append(idx.mtime, 0)
+	idx.mtimeNsec = append(idx.mtimeNsec, 0)
+	idx.offset = append(idx.offset, -1)
+	idx.dataOffset = append(idx.dataOffset, -1)
+
+	if id != 0 {
+		idx.childIDs[childKey(parent, seg)] = id
+	}
+
+	return id
+}
+
+func childKey(parent, segment uint32) uint64 {
+	return uint64(parent)<<32 | uint64(segment)
+}
+
+// node returns the node named name, creating it and its parents if needed.
+// The entry of an archive replaces the entries having the same name or the name of one of its parents:
+// parents which are not directories are made directories without header,
+// and if isDir is false an existing directory is replaced along with the nodes under it.
+func (idx *index) node(name string, isDir bool) uint32 {
+	id := uint32(0)
+	for i := 0; i <= len(name); {
+		end := strings.IndexByte(name[i:], '/')
+		if end < 0 {
+			end = len(name)
+		} else {
+			end += i
+		}
+
+		child, ok := idx.child(id, name[i:end])
+		switch {
+		case !ok:
+			child = idx.add(id, name[i:end])
+			if idx.keys != nil {
+				idx.keys[idx.normalize(name[:end])] = name[:end]
+			}
+		case end < len(name) && !idx.mode[child].IsDir():
+			idx.clear(child)
+		case end == len(name) && !isDir && idx.mode[child].IsDir():
+			child = idx.replace(child)
+		}
+
+		id, i = child, end+1
+	}
+
+	return id
+}
+
+// set sets the attributes of the node id from h.
+// offset is the offset of the headers of the entry, or -1 if it is unknown,
+// in which case blockOffset is the offset of the last block of the header.
+func (idx *index) set(id uint32, h *tar.Header, fi fs.FileInfo, offset, blockOffset, dataOffset int64) {
+	idx.mode[id] = fi.Mode()
+	idx.typeflag[id] = h.Typeflag
+	idx.size[id] = h.Size
+	idx.mtime[id] = h.ModTime.Unix()
+	idx.mtimeNsec[id] = int32(h.ModTime.Nanosecond())
+	idx.offset[id] = offset
+	idx.dataOffset[id] = dataOffset
+
+	delete(idx.headers, id)
+	if offset < 0 {
+		idx.headers[id] = eagerHeader{h, blockOffset}
+	}
+
+	delete(idx.nested, id)
+}
+
+// clear makes node id a directory without header.
+func (idx *index) clear(id uint32) {
+	idx.mode[id] = fs.ModeDir
+	idx.typeflag[id] = 0
+	idx.size[id] = 0
+	idx.mtime[id] = 0
+	idx.mtimeNsec[id] = 0
+	idx.offset[id] = -1
+	idx.dataOffset[id] = -1
+
+	delete(idx.headers, id)
+	delete(idx.nested, id)
+}
+
+// replace removes node id and the nodes under it from the tree,
+// and returns a new node having the same name, see finish.
+func (idx *index) replace(id uint32) uint32 {
+	parent := idx.parent[id]
+	idx.parent[id] = removed
+	return idx.add(parent, idx.name(id))
+}
+
+// checkEscaped checks that name, which is raw in the archive, is not the name of another entry or directory once escaped.
+// This happens when an escaped name is also a valid name, such as "a\xff" and "a%FF".
+// It returns the name in the archive of the other entry if any.
+func (idx *index) checkEscaped(name, raw string, escaped bool) (string, bool) {
+	if !escaped && len(idx.escaped) == 0 {
+		return "", true
+	}
+
+	// Names are escaped segment by segment, so their segments are aligned with the raw ones
+	end, rawEnd := 0, 0
+	for end < len(name) {
+		if i := strings.IndexByte(name[end+1:], '/'); i >= 0 {
+			end += 1 + i
+		} else {
+			end = len(name)
+		}
+		if i := strings.IndexByte(raw[rawEnd+1:], '/'); i >= 0 {
+			rawEnd += 1 + i
+		} else {
+			rawEnd = len(raw)
+		}
+		prefix, rawPrefix := name[:end], raw[:rawEnd]
+
+		if other, ok := idx.escaped[prefix]; ok {
+			if other != rawPrefix {
+				return other, false
+			}
+			continue
+		}
+
+		if prefix == rawPrefix {
+			continue
+		}
+
+		if _, ok := idx.find(0, prefix); ok {
+			return prefix, false
+		}
+		if idx.escaped == nil {
+			idx.escaped = make(map[string]string)
+		}
+		idx.escaped[prefix] = rawPrefix
+	}
+
+	return "", true
+}
+
+// child returns the child of parent named segment.
+func (idx *index) child(parent uint32, segment string) (uint32, bool) {
+	if idx.childIDs != nil {
+		seg, ok := idx.segmentIDs[segment]
+		if !ok {
+			return 0, false
+		}
+		id, ok := idx.childIDs[childKey(parent, seg)]
+		return id, ok
+	}
+
+	children := idx.children[idx.childStart[parent]:idx.childStart[parent+1]]
+	i, ok := slices.BinarySearchFunc(children, segment, func(id uint32, segment string) int {
+		return strings.Compare(idx.name(id), segment)
+	})
+	if !ok {
+		return 0, false
+	}
+	return children[i], true
+}
+
+// find returns the node named name relatively to the node root.
+func (idx *index) find(root uint32, name string) (uint32, bool) {
+	if name == "." {
+		return root, true
+	}
+
+	id := root
+	for segment := range strings.SplitSeq(name, "/") {
+		child, ok := idx.child(id, segment)
+		if !ok {
+			return 0, false
+		}
+		id = child
+	}
+	return id, true
+}
+
+// finish sorts the children of the nodes, and releases the memory used while indexing.
+// The nodes under removed nodes are removed too.
+func (idx *index) finish() {
+	n := len(idx.parent)
+
+	idx.childStart = make([]uint32, n+1)
+	for id := 1; id < n; id++ {
+		// Parents are created before their children
+		if parent := idx.parent[id]; parent == removed || idx.parent[parent] == removed {
+			idx.parent[id] = removed
+			delete(idx.headers, uint32(id))
+			delete(idx.nested, uint32(id))
+			continue
+		}
+		idx.childStart[idx.parent[id]+1]++
+	}
+	for id := 1; id <= n; id++ {
+		idx.childStart[id] += idx.childStart[id-1]
+	}
+
+	idx.children = make([]uint32, idx.childStart[n])
+	next := slices.Clone(idx.childStart[:n])
+	for id := 1; id < n; id++ {
+		parent := idx.parent[id]
+		if parent == removed {
+			continue
+		}
+		idx.children[next[parent]] = uint32(id)
+		next[parent]++
+	}
+
+	for id := range n {
+		slices.SortFunc(idx.children[idx.childStart[id]:idx.childStart[id+1]], func(a, b uint32) int {
+			return strings.Compare(idx.name(a), idx.name(b))
+		})
+	}
+
+	idx.segmentIDs, idx.childIDs, idx.escaped = nil, nil, nil
+}
+
+// len returns the number of nodes.
+func (idx *index) len() int {
+	return len(idx.parent)
+}
+
+// name returns the last segment of the name of node id.
+func (idx *index) name(id uint32) string {
+	return idx.segments[idx.segment[id]]
+}
+
+// path returns the name of node id relatively to the node root, which must be one of its parents.
+func (idx *index) path(root, id uint32) string {
+	if id == root {
+		return "."
+	}
+
+	n := 0
+	for p := id; p != root; p = idx.parent[p] {
+		n += len(idx.name(p)) + 1
+	}
+
+	b := make([]byte, n-1)
+	i := len(b)
+	for p := id; p != root; p = idx.parent[p] {
+		name := idx.name(p)
+		i -= len(name)
+		copy(b[i:], name)
+		if i--; i >= 0 {
+			b[i] = '/'
+		}
+	}
+
+	return string(b)
+}
+
+// under reports whether root is node id or one of its parents.
+// Removed nodes are under no node.
+func (idx *index) under(root, id uint32) bool {
+	if idx.parent[id] == removed {
+		return false
+	}
+	if root == 0 {
+		return true
+	}
+	for ; id != 0; id = idx.parent[id] {
+		if id == root {
+			return true
+		}
+	}
+	return false
+}
+
+// walk calls fn for node root and the nodes under it, depth first in name order,
+// with their names relatively to root.
+func (idx *index) walk(root uint32, fn func(id uint32, name string) error) error {
+	if err := fn(root, "."); err != nil {
+		return err
+	}
+	return idx.walkChildren(root, "", fn)
+}
+
+func (idx *index) walkChildren(parent uint32, prefix string, fn func(id uint32, name string) error) error {
+	for _, id := range idx.children[idx.childStart[parent]:idx.childStart[parent+1]] {
+		name := prefix + idx.name(id)
+		if err := fn(id, name); err != nil {
+			return err
+		}
+		if err := idx.walkChildren(id, name+"/", fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasHeader reports whether node id has a header in the archive.
+func (idx *index) hasHeader(id uint32) bool {
+	if idx.offset[id] >= 0 {
+		return true
+	}
+	_, ok := idx.headers[id]
+	return ok
+}
+
+// headerOffset returns the offset of the header of node id.
+func (idx *index) headerOffset(id uint32) int64 {
+	if eh, ok := idx.headers[id]; ok {
+		return eh.offset
+	}
+	return idx.offset[id]
+}
+
+// header returns the header of node id, read again from the archive if needed,
+// or nil if it has no header.
+// It returns fs.ErrClosed if the header cannot be read again because the tarfs is closed.
+func (idx *index) header(id uint32) (*tar.Header, error) {
+	if eh, ok := idx.headers[id]; ok {
+		return eh.h, nil
+	}
+
+	offset := idx.offset[id]
+	if offset < 0 {
+		return nil, nil
+	}
+
+	if !idx.h.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer idx.h.release()
+
+	ra := idx.ra
+	if mr, ok := ra.(*memReader); ok {
+		// The handle is already held, holding it again would deadlock with a Close waiting for it
+		ra = mr.Reader
+	}
+
+	h, err := tar.NewReader(io.NewSectionReader(ra, offset, 1<<63-1-offset)).Next()
+	if err != nil {
+		return nil, err
+	}
+	decodeNames(h, idx.decodeName)
+
+	return h, nil
+}
+
+// entry returns the entry of node id.
+func (idx *index) entry(id uint32) entry {
+	if ne, ok := idx.nested[id]; ok {
+		return ne
+	}
+
+	de := fs.FileInfoToDirEntry(nodeInfo{idx, id})
+
+	mode := idx.mode[id]
+	if mode.IsDir() {
+		return &dirEntry{de, idx, id}
+	}
+
+	e := idx.regEntry(id, de)
+	if isSpecial(mode) {
+		return &specialEntry{e}
+	}
+	return e
+}
+
+func (idx *index) regEntry(id uint32, de fs.DirEntry) *regEntry {
+	return &regEntry{
+		DirEntry:   de,
+		name:       idx.path(0, id),
+		ra:         idx.ra,
+		offset:     idx.headerOffset(id),
+		dataOffset: idx.dataOffset[id],
+		truncated:  int64(id) == idx.truncated,
+	}
+}
+
+// canonical returns the name under which name is indexed,
+// which is the name of the entry having the same lookup key if any.
+// Otherwise the directories of name are replaced by their canonical names.
+func (idx *index) canonical(name string) string {
+	if indexed, ok := idx.keys[idx.normalize(name)]; ok {
+		return indexed
+	}
+
+	i := strings.LastIndexByte(name, '/')
+	if i < 0 {
+		return name
+	}
+
+	return idx.canonical(name[:i]) + name[i:]
+}
+
+// nodeInfo is the fs.FileInfo of a node.
+type nodeInfo struct {
+	idx *index
+	id  uint32
+}
+
+var _ fs.FileInfo = nodeInfo{}
+
+func (fi nodeInfo) Name() string {
+	return fi.idx.name(fi.id)
+}
+
+func (fi nodeInfo) Size() int64 {
+	return fi.idx.size[fi.id]
+}
+
+func (fi nodeInfo) Mode() fs.FileMode {
+	return fi.idx.mode[fi.id]
+}
+
+func (fi nodeInfo) ModTime() time.Time {
+	if !fi.idx.hasHeader(fi.id) {
+		return time.Time{}
+	}
+	return time.Unix(fi.idx.mtime[fi.id], int64(fi.idx.mtimeNsec[fi.id]))
+}
+
+func (fi nodeInfo) IsDir() bool {
+	return fi.Mode().IsDir()
+}
+
+// Sys returns the *tar.Header of the node, or nil if it has none or if it cannot be read again, see fileHeader.
+func (fi nodeInfo) Sys() any {
+	if h, _ := fi.idx.header(fi.id); h != nil {
+		return h
+	}
+	return nil
+}
+
+// fileHeader returns the *tar.Header of the file described by info, or nil if it has none.
+// Unlike info.Sys(), it returns the error reading the header again from the archive.
+func fileHeader(info fs.FileInfo) (*tar.Header, error) {
+	if fi, ok := info.(nestedFileInfo); ok {
+		info = fi.FileInfo
+	}
+	if fi, ok := info.(nodeInfo); ok {
+		return fi.idx.header(fi.id)
+	}
+
+	h, _ := info.Sys().(*tar.Header)
+	return h, nil
+}
@@ -0,0 +1,102 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexHeaders(t *testing.T) {
+	pax := newTestArchive(t,
+		testEntry{&tar.Header{Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "global"}}, ""},
+		testEntry{&tar.Header{Name: strings.Repeat("long/", 30) + "name", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "long name"},
+		testEntry{&tar.Header{Name: "xattr", Typeflag: tar.TypeReg, Mode: 0644, PAXRecords: map[string]string{"SCHILY.xattr.user.key": "value"}}, "xattr"},
+		testEntry{&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: strings.Repeat("target/", 30)}, ""},
+		testEntry{&tar.Header{Name: "caf\xe9", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "latin1"},
+	)
+
+	for _, tc := range []struct {
+		name    string
+		archive func(t *testing.T) []byte
+	}{
+		{"test.tar", readTestFile("test.tar")},
+		{"test-sparse.tar", readTestFile("test-sparse.tar")},
+		{"test-with-global-header.tar", readTestFile("test-with-global-header.tar")},
+		{"pax", func(*testing.T) []byte { return pax }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			archive := tc.archive(t)
+
+			tfs, err := New(bytes.NewReader(archive), WithCharset("ISO-8859-1"))
+			require.NoError(err)
+
+			tr := tar.NewReader(bytes.NewReader(archive))
+			for {
+				expected, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(err)
+				if expected.Typeflag == tar.TypeXGlobalHeader {
+					continue
+				}
+				decodeNames(expected, tfs.(*tarfs).idx.decodeName)
+
+				info, err := fs.Stat(tfs, path.Clean(expected.Name))
+				require.NoError(err)
+				assert.Equal(expected, info.Sys())
+				assert.Equal(expected.FileInfo().Mode(), info.Mode())
+				assert.Equal(expected.FileInfo().Size(), info.Size())
+				assert.True(expected.ModTime.Equal(info.ModTime()))
+			}
+		})
+	}
+}
+
+func readTestFile(name string) func(t *testing.T) []byte {
+	return func(t *testing.T) []byte {
+		b, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return b
+	}
+}
+
+func TestIndexPath(t *testing.T) {
+	assert := assert.New(t)
+
+	idx := newIndex(nil, &options{})
+	a := idx.node("a", true)
+	abc := idx.node("a/bb/c", false)
+	d := idx.node("d", false)
+	idx.finish()
+
+	assert.Equal(".", idx.path(0, 0))
+	assert.Equal("a", idx.path(0, a))
+	assert.Equal("a/bb/c", idx.path(0, abc))
+	assert.Equal("bb/c", idx.path(a, abc))
+	assert.Equal(".", idx.path(a, a))
+
+	assert.True(idx.under(a, abc))
+	assert.False(idx.under(a, d))
+
+	for _, name := range []string{".", "a", "a/bb", "a/bb/c", "d"} {
+		id, ok := idx.find(0, name)
+		if assert.True(ok, name) {
+			assert.Equal(name, idx.path(0, id))
+		}
+	}
+	for _, name := range []string{"b", "a/b", "a/bb/c/d", "a/c"} {
+		_, ok := idx.find(0, name)
+		assert.False(ok, name)
+	}
+}
@@ -1,8 +1,11 @@
 package tarfs
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"io/fs"
 )
 
 type readReaderAt interface {
@@ -76,6 +79,12 @@ func (rs *readSeeker) Seek(offset int64, whence int) (int64, error) {
 		return 0, newErr(op, rs.e.name, errors.New("negative position"))
 	}
 
+	if rs.e.dataOffset >= 0 {
+		// Contiguous content is read from abs directly
+		rs.readCounter = &readCounter{rs.e.contentReader(abs), abs}
+		return abs, nil
+	}
+
 	if abs < rs.off {
 		r, err := rs.e.reader()
 		if err != nil {
@@ -93,3 +102,84 @@ func (rs *readSeeker) Seek(offset int64, whence int) (int64, error) {
 
 	return abs, nil
 }
+
+// bufferingReader reads an archive which is not an io.ReaderAt to hold it in memory,
+// until ctx is done, reporting the progress.
+type bufferingReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress func(Progress)
+	n        int64
+}
+
+func (br *bufferingReader) Read(p []byte) (int, error) {
+	if err := br.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := br.r.Read(p)
+	br.n += int64(n)
+
+	if n > 0 && br.progress != nil {
+		br.progress(Progress{Buffered: br.n})
+	}
+
+	return n, err
+}
+
+// memReader is an archive held in memory.
+type memReader struct {
+	*bytes.Reader
+	b []byte
+	// h is the handle releasing b when closed, if b is a mapping, see NewFromFile
+	h *handle
+}
+
+func newMemReader(b []byte) *memReader {
+	return &memReader{bytes.NewReader(b), b, nil}
+}
+
+// Read is like bytes.Reader.Read, but returns fs.ErrClosed once b is released.
+func (mr *memReader) Read(p []byte) (int, error) {
+	if mr.h != nil {
+		if !mr.h.acquire() {
+			return 0, fs.ErrClosed
+		}
+		defer mr.h.release()
+	}
+	return mr.Reader.Read(p)
+}
+
+// ReadAt is like bytes.Reader.ReadAt, but returns fs.ErrClosed once b is released.
+func (mr *memReader) ReadAt(p []byte, off int64) (int, error) {
+	if mr.h != nil {
+		if !mr.h.acquire() {
+			return 0, fs.ErrClosed
+		}
+		defer mr.h.release()
+	}
+	return mr.Reader.ReadAt(p, off)
+}
+
+// slice returns the n bytes at offset off, without copying them.
+func (mr *memReader) slice(off, n int64) ([]byte, bool) {
+	if off < 0 || n < 0 || off+n > int64(len(mr.b)) {
+		return nil, false
+	}
+	return mr.b[off : off+n : off+n], true
+}
+
+// truncatedReader returns io.ErrUnexpectedEOF if r ends before n bytes are read.
+type truncatedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (tr *truncatedReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	tr.n -= int64(n)
+	if err == io.EOF && tr.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,142 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"cmp"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Entry is an entry of an archive, see Entries and Stream.
+type Entry struct {
+	// Name is the name of the entry in the fs.FS
+	Name   string
+	Header *tar.Header
+	// Content reads the content of regular files, it is valid until the next iteration.
+	Content io.Reader
+}
+
+// Entries returns an iterator over the entries of fsys, which must be created by New or NewFromFile, in archive order.
+// Unlike fs.WalkDir, it reads the archive sequentially.
+// Directories which have no header in the archive are skipped,
+// and an entry overridden by a later entry with the same name is yielded once, at the position of the later header.
+// If fsys is not a tar fs.FS, the iterator yields an error wrapping errors.ErrUnsupported.
+func Entries(fsys fs.FS) iter.Seq2[Entry, error] {
+	const op = "entries"
+
+	return func(yield func(Entry, error) bool) {
+		tfs, ok := fsys.(*tarfs)
+		if !ok {
+			yield(Entry{}, newErr(op, ".", errors.ErrUnsupported))
+			return
+		}
+
+		idx := tfs.idx
+
+		var ids []uint32
+		for i := range idx.len() {
+			id := uint32(i)
+			if id != tfs.root && idx.hasHeader(id) && idx.under(tfs.root, id) {
+				ids = append(ids, id)
+			}
+		}
+		// Nodes are created in the order of the first header of their names, or of the names under them
+		slices.SortFunc(ids, func(a, b uint32) int {
+			return cmp.Compare(idx.headerOffset(a), idx.headerOffset(b))
+		})
+
+		for _, id := range ids {
+			name := idx.path(tfs.root, id)
+
+			if tfs.h.isClosed() {
+				yield(Entry{}, newErrClosed(op, name))
+				return
+			}
+
+			e := idx.entry(id)
+
+			info, err := e.Info()
+			if err != nil {
+				if !yield(Entry{}, err) {
+					return
+				}
+				continue
+			}
+
+			h, err := fileHeader(info)
+			if err != nil {
+				if !yield(Entry{}, newErr(op, name, err)) {
+					return
+				}
+				continue
+			}
+
+			content := io.Reader(strings.NewReader(""))
+			switch e := e.(type) {
+			case *regEntry:
+				if content, err = e.reader(); err != nil {
+					err = newErr(op, name, err)
+				}
+			case *nestedEntry:
+				if content, err = e.regEntry.reader(); err != nil {
+					err = newErr(op, name, err)
+				}
+			}
+
+			if !yield(Entry{name, h, content}, err) {
+				return
+			}
+		}
+	}
+}
+
+// Stream returns an iterator over the entries of the archive read from r, in a single pass.
+// Unlike New, it does not index the archive, and does not need r to implement io.ReaderAt.
+// The options related to names are taken into account, see WithCharset and WithNameDecoder.
+func Stream(r io.Reader, opts ...Option) iter.Seq2[Entry, error] {
+	o := newOptions(opts)
+
+	return func(yield func(Entry, error) bool) {
+		if o.err != nil {
+			yield(Entry{}, o.err)
+			return
+		}
+
+		tr := tar.NewReader(r)
+
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Entry{}, err)
+				return
+			}
+			if h.Typeflag == tar.TypeXGlobalHeader {
+				continue
+			}
+
+			decodeNames(h, o.decodeName)
+
+			name := path.Clean(h.Name)
+			if name == "." {
+				continue
+			}
+
+			content := io.Reader(strings.NewReader(""))
+			if h.Typeflag == tar.TypeReg || h.Typeflag == tar.TypeGNUSparse {
+				content = tr
+			}
+
+			if !yield(Entry{name, h, content}, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIterTestArchive(t *testing.T) []byte {
+	return newTestArchive(t,
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "b content"},
+		testEntry{&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		testEntry{&tar.Header{Name: "dir/a", Typeflag: tar.TypeReg, Mode: 0644}, "dir/a content"},
+		testEntry{&tar.Header{Name: "other/link", Typeflag: tar.TypeSymlink, Linkname: "../b"}, ""},
+		testEntry{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644}, "a content"},
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "new b content"},
+	)
+}
+
+type iterEntry struct {
+	name, headerName, content string
+}
+
+func collect(t *testing.T, entries func(func(Entry, error) bool)) []iterEntry {
+	t.Helper()
+
+	var collected []iterEntry
+	for e, err := range entries {
+		require.NoError(t, err)
+		content, err := io.ReadAll(e.Content)
+		require.NoError(t, err)
+		collected = append(collected, iterEntry{e.Name, e.Header.Name, string(content)})
+	}
+	return collected
+}
+
+func TestEntries(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	ra := &countingReaderAt{Reader: bytes.NewReader(newIterTestArchive(t))}
+	tfs, err := New(ra)
+	require.NoError(err)
+	ra.reset()
+
+	assert.Equal([]iterEntry{
+		{"dir", "dir/", ""},
+		{"dir/a", "dir/a", "dir/a content"},
+		{"other/link", "other/link", ""},
+		{"a", "a", "a content"},
+		{"b", "b", "new b content"},
+	}, collect(t, Entries(tfs)))
+
+	// The archive is read sequentially
+	calls := ra.reset()
+	for i := 1; i < len(calls); i++ {
+		assert.Greater(calls[i][0], calls[i-1][0])
+	}
+
+	sub, err := fs.Sub(tfs, "dir")
+	require.NoError(err)
+	assert.Equal([]iterEntry{{"a", "dir/a", "dir/a content"}}, collect(t, Entries(sub)))
+
+	n := 0
+	for range Entries(tfs) {
+		n++
+		break
+	}
+	assert.Equal(1, n)
+
+	for _, err := range Entries(fstest.MapFS{}) {
+		assert.ErrorIs(err, errors.ErrUnsupported)
+	}
+
+	require.NoError(tfs.Close())
+	for _, err := range Entries(tfs) {
+		assert.ErrorIs(err, fs.ErrClosed)
+	}
+}
+
+func TestStream(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newIterTestArchive(t)
+
+	assert.Equal([]iterEntry{
+		{"b", "b", "b content"},
+		{"dir", "dir/", ""},
+		{"dir/a", "dir/a", "dir/a content"},
+		{"other/link", "other/link", ""},
+		{"a", "a", "a content"},
+		{"b", "b", "new b content"},
+	}, collect(t, Stream(io.MultiReader(bytes.NewReader(archive)))))
+
+	// Content which is not read is skipped
+	var names []string
+	for e, err := range Stream(io.MultiReader(bytes.NewReader(archive))) {
+		require.NoError(err)
+		names = append(names, e.Name)
+	}
+	assert.Equal([]string{"b", "dir", "dir/a", "other/link", "a", "b"}, names)
+
+	latin1 := newTestArchive(t, testEntry{&tar.Header{Name: "caf\xe9", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, ""})
+	for e, err := range Stream(bytes.NewReader(latin1), WithCharset("ISO-8859-1")) {
+		require.NoError(err)
+		assert.Equal("café", e.Name)
+	}
+
+	for _, err := range Stream(bytes.NewReader(archive[:1000])) {
+		if err != nil {
+			assert.ErrorIs(err, io.ErrUnexpectedEOF)
+		}
+	}
+}
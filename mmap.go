@@ -0,0 +1,37 @@
+package tarfs
+
+import (
+	"context"
+	"os"
+)
+
+// NewFromFile creates a new tar fs.FS from the file named name.
+// On Linux the file is memory-mapped, otherwise it is read in memory.
+// The file does not need to stay opened, but the returned FS must be closed
+// to release the mapping.
+func NewFromFile(name string, opts ...Option) (FS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, unmap, err := mapFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	mr := newMemReader(data)
+
+	tfs, err := newTarfs(context.Background(), mr, newOptions(opts))
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+
+	// Reads of the mapping hold the handle, so that it is not unmapped during a read
+	mr.h = tfs.h
+	tfs.h.close = unmap
+
+	return tfs, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package tarfs
+
+import (
+	"io"
+	"os"
+)
+
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,131 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromFile(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	err = fstest.TestFS(tfs, "bar", "foo", "dir1", "dir1/dir11", "dir1/dir11/file111", "dir1/file11", "dir1/file12", "dir2", "dir2/dir21", "dir2/dir21/file211", "dir2/dir21/file212")
+	require.NoError(err)
+}
+
+func TestNewFromFileReadFile(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithZeroCopy()}} {
+		require, assert := require.New(t), assert.New(t)
+
+		tfs, err := NewFromFile("test.tar", opts...)
+		require.NoError(err)
+
+		for _, file := range []struct {
+			path    string
+			content string
+		}{
+			{"bar", "bar"},
+			{"dir1/dir11/file111", "file111"},
+			{"dir2/dir21/file212", "file212"},
+		} {
+			b, err := fs.ReadFile(tfs, file.path)
+			if !assert.NoErrorf(err, "when fs.ReadFile(tfs, %#v)", file.path) {
+				continue
+			}
+
+			assert.Equalf(file.content, string(b), "in %#v", file.path)
+		}
+
+		require.NoError(tfs.Close())
+	}
+}
+
+func TestNewFromFileSparse(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := NewFromFile("test-sparse.tar", WithZeroCopy())
+	require.NoError(err)
+	defer tfs.Close()
+
+	b, err := fs.ReadFile(tfs, "file1")
+	require.NoError(err)
+	require.Len(b, 1000000)
+
+	b, err = fs.ReadFile(tfs, "file2")
+	require.NoError(err)
+	require.Equal("file2", string(b))
+}
+
+func TestNewFromFileNotExist(t *testing.T) {
+	_, err := NewFromFile("does-not-exist.tar")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestNewFromFileConcurrentClose(t *testing.T) {
+	require := require.New(t)
+
+	content := strings.Repeat("0123456789abcdef", 1<<16)
+	name := filepath.Join(t.TempDir(), "test.tar")
+	require.NoError(os.WriteFile(name, newTestArchive(t,
+		testEntry{&tar.Header{Name: "big", Typeflag: tar.TypeReg, Mode: 0644}, content},
+	), 0o644))
+
+	for range 20 {
+		tfs, err := NewFromFile(name)
+		require.NoError(err)
+
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					var b []byte
+					var err error
+					switch i % 4 {
+					case 0:
+						b, err = fs.ReadFile(tfs, "big")
+					case 1:
+						var f fs.File
+						if f, err = tfs.Open("big"); err == nil {
+							b, err = io.ReadAll(f)
+							f.Close()
+						}
+					default:
+						// Sys reads the header again from the mapping, or returns nil once closed
+						var info fs.FileInfo
+						if info, err = fs.Stat(tfs, "big"); err == nil {
+							if h, ok := info.Sys().(*tar.Header); ok {
+								assert.Equal(t, int64(len(content)), h.Size)
+							}
+							continue
+						}
+					}
+					if err != nil {
+						assert.ErrorIs(t, err, fs.ErrClosed)
+						return
+					}
+					assert.Equal(t, len(content), len(b))
+				}
+			}()
+		}
+
+		time.Sleep(time.Millisecond)
+		require.NoError(tfs.Close())
+		wg.Wait()
+	}
+}
@@ -0,0 +1,57 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// paxHdrCharset is the PAX record telling the charset of names,
+// "BINARY" meaning that names are not UTF-8 encoded.
+const paxHdrCharset = "hdrcharset"
+
+// decodeNames decodes the name and link name of h to UTF-8.
+// Names which are not valid UTF-8, or all names if h has the PAX record hdrcharset=BINARY, are decoded with decode.
+// Names which remain invalid are escaped, see escapeName, in which case it returns true if the name of h is escaped.
+func decodeNames(h *tar.Header, decode func(string) (string, error)) bool {
+	binary := h.PAXRecords[paxHdrCharset] == "BINARY"
+	var escaped bool
+	h.Name, escaped = decodeName(h.Name, binary, decode)
+	h.Linkname, _ = decodeName(h.Linkname, binary, decode)
+	return escaped
+}
+
+func decodeName(name string, binary bool, decode func(string) (string, error)) (string, bool) {
+	if decode != nil && (binary || !utf8.ValidString(name)) {
+		// U+FFFD is the replacement character of x/text decoders for invalid input
+		if decoded, err := decode(name); err == nil && utf8.ValidString(decoded) && !strings.ContainsRune(decoded, utf8.RuneError) {
+			return decoded, false
+		}
+	}
+
+	if utf8.ValidString(name) {
+		return name, false
+	}
+
+	return escapeName(name), true
+}
+
+// escapeName replaces the bytes of name which are not valid UTF-8, and '%', by their %XX escape.
+// As valid names are not escaped, an escaped name may be the name of another entry, see index.checkEscaped.
+func escapeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, "%%%02X", name[i])
+		case r == '%':
+			b.WriteString("%25")
+		default:
+			b.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
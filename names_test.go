@@ -0,0 +1,173 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestNonUTF8Names(t *testing.T) {
+	sjis, err := japanese.ShiftJIS.NewEncoder().String("日本語.txt")
+	require.NoError(t, err)
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "dir\xe9/caf\xe9.txt", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "latin1"},
+		testEntry{&tar.Header{Name: sjis, Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "sjis"},
+		testEntry{&tar.Header{Name: "100%\xff", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "percent"},
+		testEntry{&tar.Header{Name: "link\xe9", Typeflag: tar.TypeSymlink, Linkname: "dir\xe9/caf\xe9.txt", Format: tar.FormatGNU}, ""},
+		testEntry{&tar.Header{Name: "100%", Typeflag: tar.TypeReg, Mode: 0644}, "valid"},
+	)
+
+	for _, tc := range []struct {
+		name  string
+		opts  []Option
+		files map[string]string
+		link  string
+	}{
+		{
+			name: "escaped",
+			files: map[string]string{
+				"dir%E9/caf%E9.txt":    "latin1",
+				"%93%FA%96{%8C%EA.txt": "sjis",
+				"100%25%FF":            "percent",
+				"100%":                 "valid",
+			},
+			link: "dir%E9/caf%E9.txt",
+		},
+		{
+			name: "latin1",
+			opts: []Option{WithCharset("ISO-8859-1")},
+			files: map[string]string{
+				"diré/café.txt":             "latin1",
+				"\u0093ú\u0096{\u008cê.txt": "sjis",
+				"100%ÿ":                     "percent",
+				"100%":                      "valid",
+			},
+			link: "diré/café.txt",
+		},
+		{
+			name: "shift_jis",
+			opts: []Option{WithCharset("Shift_JIS")},
+			files: map[string]string{
+				"dir%E9/caf%E9.txt": "latin1",
+				"日本語.txt":           "sjis",
+				"100%25%FF":         "percent",
+				"100%":              "valid",
+			},
+			link: "dir%E9/caf%E9.txt",
+		},
+		{
+			name: "decoder error",
+			opts: []Option{WithNameDecoder(func(string) (string, error) { return "", errors.New("error") })},
+			files: map[string]string{
+				"dir%E9/caf%E9.txt": "latin1",
+				"100%25%FF":         "percent",
+			},
+			link: "dir%E9/caf%E9.txt",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			tfs, err := New(bytes.NewReader(archive), tc.opts...)
+			require.NoError(err)
+
+			expected := make([]string, 0, len(tc.files))
+			for name, content := range tc.files {
+				b, err := fs.ReadFile(tfs, name)
+				if assert.NoErrorf(err, "ReadFile(%q)", name) {
+					assert.Equal(content, string(b))
+				}
+				expected = append(expected, name)
+			}
+
+			entries, err := fs.ReadDir(tfs, ".")
+			require.NoError(err)
+			for _, e := range entries {
+				if e.Type() == fs.ModeSymlink {
+					info, err := e.Info()
+					require.NoError(err)
+					assert.Equal(tc.link, info.Sys().(*tar.Header).Linkname)
+				}
+			}
+
+			require.NoError(fstest.TestFS(tfs, expected...))
+		})
+	}
+}
+
+func TestHdrCharsetBinary(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "\xc3\xa9", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatPAX, PAXRecords: map[string]string{"hdrcharset": "BINARY"}}, "binary"},
+		testEntry{&tar.Header{Name: "\xc3\xa8", Typeflag: tar.TypeReg, Mode: 0644}, "utf8"},
+	)
+
+	tfs, err := New(bytes.NewReader(archive), WithCharset("ISO-8859-1"))
+	require.NoError(err)
+
+	b, err := fs.ReadFile(tfs, "Ã©")
+	require.NoError(err)
+	assert.Equal("binary", string(b))
+
+	b, err = fs.ReadFile(tfs, "è")
+	require.NoError(err)
+	assert.Equal("utf8", string(b))
+}
+
+func TestWithCharsetUnsupported(t *testing.T) {
+	_, err := New(bytes.NewReader(newTestArchive(t)), WithCharset("unknown"))
+	require.Error(t, err)
+}
+
+func TestEscapedNameCollision(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []testEntry
+	}{
+		{
+			name: "escaped then valid",
+			entries: []testEntry{
+				{&tar.Header{Name: "a\xff", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "invalid"},
+				{&tar.Header{Name: "a%FF", Typeflag: tar.TypeReg, Mode: 0644}, "valid"},
+			},
+		},
+		{
+			name: "valid then escaped",
+			entries: []testEntry{
+				{&tar.Header{Name: "a%FF", Typeflag: tar.TypeReg, Mode: 0644}, "valid"},
+				{&tar.Header{Name: "a\xff", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "invalid"},
+			},
+		},
+		{
+			name: "directories",
+			entries: []testEntry{
+				{&tar.Header{Name: "d\xff/invalid", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "invalid"},
+				{&tar.Header{Name: "d%FF/valid", Typeflag: tar.TypeReg, Mode: 0644}, "valid"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(bytes.NewReader(newTestArchive(t, tc.entries...)))
+			require.ErrorIs(t, err, ErrNameCollision)
+		})
+	}
+
+	// Escaped names of the same raw name do not collide
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "d\xff/a", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "a"},
+		testEntry{&tar.Header{Name: "d\xff/b", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "b"},
+		testEntry{&tar.Header{Name: "d\xff/a", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatGNU}, "a2"},
+		testEntry{&tar.Header{Name: "d/a%FF", Typeflag: tar.TypeReg, Mode: 0644}, "valid"},
+	)))
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(tfs, "d%FF/a", "d%FF/b", "d/a%FF"))
+}
@@ -0,0 +1,185 @@
+package tarfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// nestedEntry is a regular file holding a tar archive, mounted as a directory.
+type nestedEntry struct {
+	*regEntry
+	o *options
+	h *handle
+
+	once sync.Once
+	tfs  *tarfs
+	err  error
+}
+
+var _ entry = &nestedEntry{}
+
+func (e *nestedEntry) IsDir() bool {
+	return true
+}
+
+func (e *nestedEntry) Type() fs.FileMode {
+	return fs.ModeDir
+}
+
+func (e *nestedEntry) Info() (fs.FileInfo, error) {
+	fi, err := e.regEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return nestedFileInfo{fi}, nil
+}
+
+func (e *nestedEntry) size() int64 {
+	return 0
+}
+
+func (e *nestedEntry) readdir(path string) ([]fs.DirEntry, error) {
+	tfs, err := e.mount()
+	if err != nil {
+		return nil, newErr("readdir", path, err)
+	}
+
+	return tfs.idx.entry(tfs.root).readdir(path)
+}
+
+func (e *nestedEntry) readfile(path string) ([]byte, error) {
+	return nil, newErrDir("readfile", path)
+}
+
+func (e *nestedEntry) entries(op, path string) ([]fs.DirEntry, error) {
+	tfs, err := e.mount()
+	if err != nil {
+		return nil, newErr(op, path, err)
+	}
+
+	return tfs.idx.entry(tfs.root).entries(op, path)
+}
+
+func (e *nestedEntry) open(path string) (*file, error) {
+	return &file{entry: e}, nil
+}
+
+// mount indexes the nested archive on first call.
+func (e *nestedEntry) mount() (*tarfs, error) {
+	e.once.Do(func() {
+		e.tfs, e.err = e.newTarfs()
+	})
+	return e.tfs, e.err
+}
+
+func (e *nestedEntry) newTarfs() (*tarfs, error) {
+	var ra readReaderAt
+
+	if b, ok := e.bytes(); ok {
+		// The nested archive shares the mapping of the archive, if any
+		ra = &memReader{bytes.NewReader(b), b, e.ra.(*memReader).h}
+	} else if e.dataOffset >= 0 {
+		ra = io.NewSectionReader(e.ra, e.dataOffset, e.regEntry.size())
+	} else {
+		r, err := e.reader()
+		if err != nil {
+			return nil, err
+		}
+		buf, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		ra = newMemReader(buf)
+	}
+
+	var magic [2]byte
+	if _, err := ra.ReadAt(magic[:], 0); err == nil && magic == [2]byte{0x1f, 0x8b} {
+		zr, err := gzip.NewReader(io.NewSectionReader(ra, 0, e.regEntry.size()))
+		if err != nil {
+			return nil, err
+		}
+		buf, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		ra = newMemReader(buf)
+	}
+
+	// Progress and recovery are reported while indexing the top level archive only
+	o := *e.o
+	o.progress = nil
+	o.report = nil
+
+	tfs, err := newTarfs(context.Background(), ra, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	tfs.h = e.h
+	tfs.idx.h = e.h
+
+	return tfs, nil
+}
+
+// glob returns the names matching pattern inside the nested archive named name.
+func (e *nestedEntry) glob(name, pattern string) ([]string, error) {
+	n := strings.Count(name, "/") + 1
+
+	parts := strings.SplitN(pattern, "/", n+1)
+	if len(parts) <= n {
+		return nil, nil
+	}
+
+	if match, _ := path.Match(strings.Join(parts[:n], "/"), name); !match {
+		return nil, nil
+	}
+
+	tfs, err := e.mount()
+	if err != nil {
+		return nil, newErr("glob", name, err)
+	}
+
+	nestedMatches, err := tfs.Glob(parts[n])
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]string, 0, len(nestedMatches))
+	for _, match := range nestedMatches {
+		if match != "." {
+			matches = append(matches, name+"/"+match)
+		}
+	}
+
+	return matches, nil
+}
+
+type nestedFileInfo struct {
+	fs.FileInfo
+}
+
+func (fi nestedFileInfo) Mode() fs.FileMode {
+	perm := fi.FileInfo.Mode().Perm()
+	return fs.ModeDir | perm | (perm&0444)>>2
+}
+
+func (fi nestedFileInfo) IsDir() bool {
+	return true
+}
+
+func matchSuffixes(suffixes []string) func(string) bool {
+	return func(name string) bool {
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(name, suffix) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNestedTestArchive(t *testing.T) []byte {
+	t.Helper()
+
+	inner, err := os.ReadFile("test.tar")
+	require.NoError(t, err)
+
+	var gzInner bytes.Buffer
+	zw := gzip.NewWriter(&gzInner)
+	_, err = zw.Write(inner)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{"bundle/comp.tar", inner},
+		{"bundle/comp.tar.gz", gzInner.Bytes()},
+		{"bundle/README", []byte("readme")},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(file.content)),
+		}))
+		_, err := tw.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func TestNestedArchives(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := New(bytes.NewReader(newNestedTestArchive(t)), WithNestedArchives())
+	require.NoError(err)
+
+	expected := []string{"bundle", "bundle/README", "bundle/comp.tar", "bundle/comp.tar.gz"}
+	for _, dir := range []string{"bundle/comp.tar", "bundle/comp.tar.gz"} {
+		for _, name := range []string{"bar", "foo", "dir1", "dir1/dir11", "dir1/dir11/file111", "dir1/file11", "dir1/file12", "dir2", "dir2/dir21", "dir2/dir21/file211", "dir2/dir21/file212"} {
+			expected = append(expected, dir+"/"+name)
+		}
+	}
+
+	err = fstest.TestFS(tfs, expected...)
+	require.NoError(err)
+}
+
+func TestNestedArchivesOpen(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := New(bytes.NewReader(newNestedTestArchive(t)), WithNestedArchives())
+	require.NoError(err)
+
+	for _, name := range []string{"bundle/comp.tar", "bundle/comp.tar.gz"} {
+		fi, err := fs.Stat(tfs, name)
+		if assert.NoErrorf(err, "when fs.Stat(tfs, %#v)", name) {
+			assert.Truef(fi.IsDir(), "FileInfo{%#v}.IsDir()", name)
+		}
+
+		entries, err := fs.ReadDir(tfs, name)
+		if assert.NoErrorf(err, "when fs.ReadDir(tfs, %#v)", name) {
+			assert.Lenf(entries, 4, "entries of %#v", name)
+		}
+
+		b, err := fs.ReadFile(tfs, name+"/dir2/dir21/file211")
+		if assert.NoErrorf(err, "when fs.ReadFile(tfs, %#v)", name+"/dir2/dir21/file211") {
+			assert.Equal("file211", string(b))
+		}
+
+		_, err = tfs.Open(name + "/baz")
+		assert.ErrorIs(err, fs.ErrNotExist)
+		var pathErr *fs.PathError
+		if assert.ErrorAs(err, &pathErr) {
+			assert.Equal(name+"/baz", pathErr.Path)
+		}
+
+		subfs, err := fs.Sub(tfs, name+"/dir1")
+		if assert.NoErrorf(err, "when fs.Sub(tfs, %#v)", name+"/dir1") {
+			b, err := fs.ReadFile(subfs, "file12")
+			assert.NoError(err)
+			assert.Equal("file12", string(b))
+		}
+	}
+
+	b, err := fs.ReadFile(tfs, "bundle/README")
+	require.NoError(err)
+	require.Equal("readme", string(b))
+}
+
+func TestNestedArchivesFunc(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := New(bytes.NewReader(newNestedTestArchive(t)), WithNestedArchivesFunc(func(name string) bool {
+		return name == "bundle/comp.tar"
+	}))
+	require.NoError(err)
+
+	fi, err := fs.Stat(tfs, "bundle/comp.tar")
+	require.NoError(err)
+	require.True(fi.IsDir())
+
+	fi, err = fs.Stat(tfs, "bundle/comp.tar.gz")
+	require.NoError(err)
+	require.False(fi.IsDir())
+}
+
+func TestNestedArchivesInvalid(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := New(bytes.NewReader(newNestedTestArchive(t)), WithNestedArchives("README"))
+	require.NoError(err)
+
+	_, err = fs.ReadDir(tfs, "bundle/README")
+	require.Error(err)
+}
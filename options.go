@@ -0,0 +1,195 @@
+package tarfs
+
+import (
+	"fmt"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Option configures the fs.FS created by New or NewFromFile.
+type Option func(*options)
+
+type options struct {
+	zeroCopy    bool
+	ownedReader bool
+	nested      func(name string) bool
+	caseFold    bool
+	normForm    *norm.Form
+	decodeName  func(string) (string, error)
+	ignoreZeros bool
+	recovery    bool
+	report      *RecoveryReport
+	resync      bool
+	progress    func(Progress)
+	cache       *CacheOptions
+	parallel    *parallelOptions
+	// err is an invalid option error, returned by New
+	err error
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithZeroCopy makes ReadFile return slices of the archive data instead of copies,
+// when the archive data is held in memory (see NewFromFile).
+// The returned slices must not be modified, and must not be used after the fs.FS is closed.
+func WithZeroCopy() Option {
+	return func(o *options) {
+		o.zeroCopy = true
+	}
+}
+
+// WithOwnedReader makes the fs.FS own the io.Reader given to New:
+// closing the fs.FS closes the io.Reader if it implements io.Closer.
+func WithOwnedReader() Option {
+	return func(o *options) {
+		o.ownedReader = true
+	}
+}
+
+// WithNestedArchives mounts the archives contained in the archive as directories,
+// when their name ends with one of suffixes (.tar, .tar.gz and .tgz if none is given).
+// Nested archives may be gzip compressed, in which case they are decompressed in memory.
+func WithNestedArchives(suffixes ...string) Option {
+	if len(suffixes) == 0 {
+		suffixes = []string{".tar", ".tar.gz", ".tgz"}
+	}
+	return WithNestedArchivesFunc(matchSuffixes(suffixes))
+}
+
+// WithNestedArchivesFunc mounts the archives contained in the archive as directories,
+// when match returns true for their name.
+// See WithNestedArchives.
+func WithNestedArchivesFunc(match func(name string) bool) Option {
+	return func(o *options) {
+		o.nested = match
+	}
+}
+
+// WithIgnoreZeros makes New continue reading after the end-of-archive zero blocks,
+// like tar --ignore-zeros, in order to read concatenated archives as one.
+func WithIgnoreZeros() Option {
+	return func(o *options) {
+		o.ignoreZeros = true
+	}
+}
+
+// WithRecovery makes New return the entries of a truncated or corrupt archive read before the error,
+// instead of the error.
+// If the content of the last entry is truncated, reading it returns io.ErrUnexpectedEOF.
+// New fills report, if not nil, with what could not be read.
+// Nested archives, see WithNestedArchives, are recovered too, but are not reported.
+func WithRecovery(report *RecoveryReport) Option {
+	return func(o *options) {
+		o.recovery = true
+		o.report = report
+	}
+}
+
+// WithResync makes New, in recovery mode, skip corrupt headers and continue reading at the next block
+// having a valid header checksum, instead of stopping.
+// See WithRecovery.
+func WithResync() Option {
+	return func(o *options) {
+		o.resync = true
+	}
+}
+
+// Progress is the progress of indexing an archive, see WithProgress.
+type Progress struct {
+	// Entries is the number of headers read
+	Entries int
+	// Bytes is the number of bytes of the archive read
+	Bytes int64
+	// Buffered is the number of bytes read from the io.Reader given to New,
+	// if it is not an io.ReaderAt and is read in memory before the archive is indexed
+	Buffered int64
+}
+
+// WithProgress makes New call progress after reading each header of the archive,
+// and while the io.Reader given to New is read in memory, if it is not an io.ReaderAt.
+func WithProgress(progress func(Progress)) Option {
+	return func(o *options) {
+		o.progress = progress
+	}
+}
+
+// WithCaseInsensitive makes the lookup of names case insensitive, using Unicode case folding.
+// The names returned by ReadDir, Glob and Stat keep the case stored in the archive.
+// New returns an error wrapping ErrNameCollision if two entries of the archive have names folding to the same key.
+func WithCaseInsensitive() Option {
+	return func(o *options) {
+		o.caseFold = true
+	}
+}
+
+// WithUnicodeNormalization makes the lookup of names insensitive to their Unicode normalization form,
+// for example to open NFD decomposed names, as stored by macOS, with NFC composed names.
+// Both the names of the archive and the looked up names are normalized to form.
+// The names returned by ReadDir, Glob and Stat keep the form stored in the archive.
+// New returns an error wrapping ErrNameCollision if two entries of the archive have names normalizing to the same key.
+func WithUnicodeNormalization(form norm.Form) Option {
+	return func(o *options) {
+		o.normForm = &form
+	}
+}
+
+// normalizer returns the function computing the lookup key of a name,
+// or nil if names are looked up as is.
+func (o *options) normalizer() func(string) string {
+	if !o.caseFold && o.normForm == nil {
+		return nil
+	}
+
+	fold := cases.Fold()
+	return func(name string) string {
+		if o.caseFold {
+			name = fold.String(name)
+		}
+		if o.normForm != nil {
+			name = o.normForm.String(name)
+		}
+		return name
+	}
+}
+
+// WithCharset decodes the names of the archive which are not valid UTF-8 from charset,
+// an IANA charset name such as "ISO-8859-1" or "Shift_JIS".
+// The names of entries having the PAX record hdrcharset=BINARY are always decoded.
+// New returns an error if charset is not supported.
+// See WithNameDecoder.
+func WithCharset(charset string) Option {
+	return func(o *options) {
+		enc, err := ianaindex.IANA.Encoding(charset)
+		if err == nil && enc == nil {
+			err = fmt.Errorf("unsupported charset %q", charset)
+		}
+		if err != nil {
+			o.err = err
+			return
+		}
+
+		o.decodeName = func(name string) (string, error) {
+			return enc.NewDecoder().String(name)
+		}
+	}
+}
+
+// WithNameDecoder decodes the names of the archive which are not valid UTF-8 with decode.
+// The names of entries having the PAX record hdrcharset=BINARY are always decoded.
+//
+// Names which cannot be decoded, because decode returns an error or a result which is not valid UTF-8,
+// have their invalid bytes and '%' replaced by %XX escapes, so that they can be opened.
+// This is done even if no decoder is given.
+func WithNameDecoder(decode func(name string) (string, error)) Option {
+	return func(o *options) {
+		o.decodeName = decode
+	}
+}
@@ -0,0 +1,139 @@
+package tarfs
+
+import (
+	"io"
+	"sync"
+)
+
+type parallelOptions struct {
+	chunkSize int64
+	workers   int
+}
+
+// WithParallelReads makes ReadFile, and the WriteTo method of files, read the content of files larger than chunkSize
+// in chunks of chunkSize bytes, fetched concurrently by up to workers goroutines.
+// This is useful when reading the io.ReaderAt given to New has a high latency, see NewHTTP.
+// It has no effect on sparse files and archives held in memory.
+func WithParallelReads(chunkSize int64, workers int) Option {
+	return func(o *options) {
+		if chunkSize > 0 && workers > 1 {
+			o.parallel = &parallelOptions{chunkSize, workers}
+		} else {
+			o.parallel = nil
+		}
+	}
+}
+
+// parallelizable reports whether the content of e from off is read in parallel with p.
+func (e *regEntry) parallelizable(p *parallelOptions, off int64) bool {
+	if p == nil || e.dataOffset < 0 {
+		return false
+	}
+	if _, isMem := e.ra.(*memReader); isMem {
+		return false
+	}
+	return e.size()-off > p.chunkSize
+}
+
+// readChunk reads exactly len(b) bytes of the content of e at off.
+func (e *regEntry) readChunk(b []byte, off int64) error {
+	n, err := e.ra.ReadAt(b, e.dataOffset+off)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readParallel reads the content of e, see WithParallelReads.
+func (e *regEntry) readParallel(p *parallelOptions) ([]byte, error) {
+	b := make([]byte, e.size())
+
+	chunks := make(chan int64)
+	errs := make([]error, p.workers)
+
+	var wg sync.WaitGroup
+	for i := range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for off := range chunks {
+				if errs[i] == nil {
+					errs[i] = e.readChunk(b[off:min(off+p.chunkSize, int64(len(b)))], off)
+				}
+			}
+		}()
+	}
+
+	for off := int64(0); off < int64(len(b)); off += p.chunkSize {
+		chunks <- off
+	}
+	close(chunks)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+type chunkResult struct {
+	b   []byte
+	err error
+}
+
+// writeParallel writes the content of e from off to w, see WithParallelReads.
+// At most p.workers chunks are held in memory, and they are written in order.
+func (e *regEntry) writeParallel(w io.Writer, off int64, p *parallelOptions) (int64, error) {
+	size := e.size()
+
+	// tokens limits the number of chunks being fetched or waiting to be written
+	tokens := make(chan struct{}, p.workers)
+	done := make(chan struct{})
+	defer close(done)
+
+	results := make(chan chan chunkResult, p.workers)
+
+	go func() {
+		defer close(results)
+		for chunkOff := off; chunkOff < size; chunkOff += p.chunkSize {
+			select {
+			case tokens <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			result := make(chan chunkResult, 1)
+			results <- result
+
+			go func(chunkOff int64) {
+				b := make([]byte, min(p.chunkSize, size-chunkOff))
+				err := e.readChunk(b, chunkOff)
+				result <- chunkResult{b, err}
+			}(chunkOff)
+		}
+	}()
+
+	var written int64
+	for result := range results {
+		r := <-result
+		if r.err != nil {
+			return written, r.err
+		}
+
+		n, err := w.Write(r.b)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+
+		<-tokens
+	}
+
+	return written, nil
+}
@@ -0,0 +1,84 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithParallelReads(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	content := make([]byte, 10000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "large", Typeflag: tar.TypeReg, Mode: 0644}, string(content)},
+		testEntry{&tar.Header{Name: "small", Typeflag: tar.TypeReg, Mode: 0644}, "small"},
+	)
+
+	ra := &countingReaderAt{Reader: bytes.NewReader(archive)}
+	tfs, err := New(ra, WithParallelReads(1000, 4))
+	require.NoError(err)
+	ra.reset()
+
+	b, err := fs.ReadFile(tfs, "large")
+	require.NoError(err)
+	assert.Equal(content, b)
+	assert.Len(ra.reset(), 10)
+
+	b, err = fs.ReadFile(tfs, "small")
+	require.NoError(err)
+	assert.Equal("small", string(b))
+
+	f, err := tfs.Open("large")
+	require.NoError(err)
+	defer f.Close()
+
+	_, err = f.(io.Seeker).Seek(1500, io.SeekStart)
+	require.NoError(err)
+	ra.reset()
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, f)
+	require.NoError(err)
+	assert.Equal(int64(8500), n)
+	assert.Equal(content[1500:], buf.Bytes())
+	assert.Len(ra.reset(), 9)
+
+	// The offset is at the end of the file
+	n, err = io.Copy(&buf, f)
+	require.NoError(err)
+	assert.Zero(n)
+}
+
+func TestWithParallelReadsTruncated(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "large", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("a", 10000)},
+	)
+
+	tfs, err := New(bytes.NewReader(archive[:5000]), WithRecovery(nil), WithParallelReads(1000, 4))
+	require.NoError(err)
+
+	_, err = fs.ReadFile(tfs, "large")
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+
+	f, err := tfs.Open("large")
+	require.NoError(err)
+	defer f.Close()
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, f)
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+	assert.Equal(int64(4000), n)
+}
@@ -0,0 +1,151 @@
+package tarfs
+
+import (
+	"io"
+)
+
+// RecoveryReport describes what could not be read from a truncated or corrupt archive, see WithRecovery.
+type RecoveryReport struct {
+	// Truncated is the name of the last entry of the archive if its content is truncated.
+	// Reading it returns io.ErrUnexpectedEOF.
+	Truncated string
+
+	// Skipped are the byte ranges of the archive which could not be read.
+	Skipped []SkippedRange
+}
+
+// SkippedRange is a byte range of an archive skipped because of Err.
+type SkippedRange struct {
+	Offset int64
+	// Size is -1 if the range extends to the end of an archive of unknown size.
+	Size int64
+	Err  error
+}
+
+// recoverer recovers from the errors returned by tar.Reader.Next.
+type recoverer struct {
+	idx    *index
+	report *RecoveryReport
+	resync bool
+
+	// end is the offset of the end of the last entry, or -1 if it is unknown
+	end int64
+	// last is the node of the last entry if its content is contiguous, or -1
+	last int64
+}
+
+func newRecoverer(idx *index, o *options) *recoverer {
+	report := o.report
+	if report == nil {
+		report = &RecoveryReport{}
+	}
+	*report = RecoveryReport{}
+
+	return &recoverer{idx: idx, report: report, resync: o.resync, last: -1}
+}
+
+// next records end, the offset of the end of the entry read by tar.Reader.Next, see entryEnd.
+func (r *recoverer) next(end int64) {
+	r.end = end
+	r.last = -1
+}
+
+// recover handles err, returned by tar.Reader.Next after reading up to off.
+// It returns the offset at which reading resumes, or -1 if it stops.
+func (r *recoverer) recover(err error, off int64) (int64, error) {
+	start := r.end
+	if start < 0 {
+		start = max(off-blockSize, 0)
+	}
+
+	if err == io.ErrUnexpectedEOF && r.last >= 0 && r.isTruncated(uint32(r.last)) {
+		r.idx.truncated = r.last
+		r.report.Truncated = r.idx.path(0, uint32(r.last))
+		return -1, nil
+	}
+
+	size := readerAtSize(r.idx.ra)
+
+	if err == io.ErrUnexpectedEOF || !r.resync {
+		if size < 0 || start < size {
+			r.skip(start, size, err)
+		}
+		return -1, nil
+	}
+
+	next, scanErr := nextHeader(r.idx.ra, start+blockSize)
+	if scanErr != nil {
+		return -1, scanErr
+	}
+
+	r.skip(start, next, err)
+
+	if next >= 0 {
+		r.end = next
+	}
+
+	return next, nil
+}
+
+// skip records the range from start to end (-1 for the end of the archive) as skipped.
+func (r *recoverer) skip(start, end int64, err error) {
+	size := int64(-1)
+	if end >= 0 {
+		size = end - start
+	} else if archiveSize := readerAtSize(r.idx.ra); archiveSize >= 0 {
+		size = archiveSize - start
+	}
+	r.report.Skipped = append(r.report.Skipped, SkippedRange{start, size, err})
+}
+
+// isTruncated reports whether the content of node id extends past the end of the archive.
+func (r *recoverer) isTruncated(id uint32) bool {
+	size := r.idx.size[id]
+	if size == 0 {
+		return false
+	}
+
+	var b [1]byte
+	_, err := r.idx.ra.ReadAt(b[:], r.idx.dataOffset[id]+size-1)
+	return err != nil
+}
+
+// nextHeader returns the offset of the first block with a valid header checksum, starting at off,
+// or -1 if there is none.
+func nextHeader(ra io.ReaderAt, off int64) (int64, error) {
+	var blk [blockSize]byte
+	for ; ; off += blockSize {
+		if _, err := ra.ReadAt(blk[:], off); err != nil {
+			if err == io.EOF {
+				return -1, nil
+			}
+			return -1, err
+		}
+		if isHeader(blk[:]) {
+			return off, nil
+		}
+	}
+}
+
+// readerAtSize returns the size of ra, or -1 if it is unknown.
+func readerAtSize(ra io.ReaderAt) int64 {
+	switch ra := ra.(type) {
+	case interface{ Size() int64 }:
+		return ra.Size()
+	case io.Seeker:
+		cur, err := ra.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		size, err := ra.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err := ra.Seek(cur, io.SeekStart); err != nil {
+			return -1
+		}
+		return size
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,139 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRecoveryTestArchive returns an archive with headers at 0, 1536 and 2560,
+// and content at 512, 2048 and 3072.
+func newRecoveryTestArchive(t *testing.T) []byte {
+	return newTestArchive(t,
+		testEntry{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("a", 600)},
+		testEntry{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "b content"},
+		testEntry{&tar.Header{Name: "c", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("c", 200)},
+	)
+}
+
+func TestRecoveryTruncatedContent(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newRecoveryTestArchive(t)[:3072+100]
+
+	var report RecoveryReport
+	tfs, err := New(bytes.NewReader(archive), WithRecovery(&report))
+	require.NoError(err)
+
+	assert.Equal("c", report.Truncated)
+	assert.Empty(report.Skipped)
+
+	b, err := fs.ReadFile(tfs, "b")
+	require.NoError(err)
+	assert.Equal("b content", string(b))
+
+	_, err = fs.ReadFile(tfs, "c")
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+
+	f, err := tfs.Open("c")
+	require.NoError(err)
+	defer f.Close()
+
+	buf := make([]byte, 50)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 80)
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+	assert.Equal(20, n)
+}
+
+func TestRecoveryTruncatedHeader(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	archive := newRecoveryTestArchive(t)[:2560+100]
+
+	var report RecoveryReport
+	tfs, err := New(bytes.NewReader(archive), WithRecovery(&report))
+	require.NoError(err)
+
+	assert.Empty(report.Truncated)
+	assert.Equal([]SkippedRange{{2560, 100, io.ErrUnexpectedEOF}}, report.Skipped)
+
+	require.NoError(fstest.TestFS(tfs, "a", "b"))
+}
+
+func TestRecoveryCorruptHeader(t *testing.T) {
+	archive := newRecoveryTestArchive(t)
+	archive[1536] = 'x'
+
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		files   []string
+		skipped []SkippedRange
+	}{
+		{
+			name:    "stop",
+			files:   []string{"a"},
+			skipped: []SkippedRange{{1536, int64(len(archive)) - 1536, tar.ErrHeader}},
+		},
+		{
+			name:    "resync",
+			opts:    []Option{WithResync()},
+			files:   []string{"a", "c"},
+			skipped: []SkippedRange{{1536, 1024, tar.ErrHeader}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			var report RecoveryReport
+			tfs, err := New(bytes.NewReader(archive), append(tc.opts, WithRecovery(&report))...)
+			require.NoError(err)
+
+			assert.Empty(report.Truncated)
+			assert.Equal(tc.skipped, report.Skipped)
+
+			require.NoError(fstest.TestFS(tfs, tc.files...))
+		})
+	}
+}
+
+func TestWithoutRecovery(t *testing.T) {
+	archive := newRecoveryTestArchive(t)
+	archive[1536] = 'x'
+
+	_, err := New(bytes.NewReader(archive))
+	assert.ErrorIs(t, err, tar.ErrHeader)
+
+	_, err = New(bytes.NewReader(newRecoveryTestArchive(t)[:2560+100]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestRecoveryNestedArchives(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	inner := newTestArchive(t, testEntry{&tar.Header{Name: "in.txt", Typeflag: tar.TypeReg, Mode: 0644}, "in"})
+	archive := newTestArchive(t,
+		testEntry{&tar.Header{Name: "n.tar", Typeflag: tar.TypeReg, Mode: 0644}, string(inner)},
+		testEntry{&tar.Header{Name: "big", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("b", 1000)},
+	)
+	archive = archive[:len(inner)+1024+100]
+
+	var report RecoveryReport
+	tfs, err := New(bytes.NewReader(archive), WithRecovery(&report), WithNestedArchives())
+	require.NoError(err)
+	assert.Equal("big", report.Truncated)
+
+	b, err := fs.ReadFile(tfs, "n.tar/in.txt")
+	require.NoError(err)
+	assert.Equal("in", string(b))
+
+	assert.Equal("big", report.Truncated)
+	assert.Empty(report.Skipped)
+}
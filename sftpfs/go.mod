@@ -0,0 +1,19 @@
+module github.com/nlepage/go-tarfs/sftpfs
+
+go 1.25.0
+
+require (
+	github.com/nlepage/go-tarfs v0.0.0-20261018212219-5d7dfdda1dcc
+	github.com/pkg/sftp v1.13.11
+	github.com/stretchr/testify v1.11.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.55.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
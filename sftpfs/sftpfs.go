@@ -0,0 +1,186 @@
+// Package sftpfs serves an fs.FS, such as one created by package tarfs, read-only over SFTP.
+package sftpfs
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+)
+
+// Handlers returns sftp.Handlers serving the contents of fsys, to be used with sftp.NewRequestServer.
+// Requests modifying the file system fail with sftp.ErrSSHFxPermissionDenied.
+//
+// Files implementing io.ReaderAt, such as those of package tarfs, are read at the offsets requested by the client.
+func Handlers(fsys fs.FS) sftp.Handlers {
+	h := &handlers{fsys}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+type handlers struct {
+	fsys fs.FS
+}
+
+var (
+	_ sftp.FileReader         = (*handlers)(nil)
+	_ sftp.FileWriter         = (*handlers)(nil)
+	_ sftp.FileCmder          = (*handlers)(nil)
+	_ sftp.ReadlinkFileLister = (*handlers)(nil)
+)
+
+func (h *handlers) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	f, err := h.fsys.Open(fsName(r.Filepath))
+	if err != nil {
+		return nil, err
+	}
+
+	if ra, ok := f.(readerAtCloser); ok {
+		return ra, nil
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return &seekReaderAt{rs: rs, f: f}, nil
+	}
+
+	f.Close()
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+func (*handlers) Filewrite(*sftp.Request) (io.WriterAt, error) {
+	return nil, sftp.ErrSSHFxPermissionDenied
+}
+
+func (*handlers) Filecmd(*sftp.Request) error {
+	return sftp.ErrSSHFxPermissionDenied
+}
+
+func (h *handlers) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	name := fsName(r.Filepath)
+
+	switch r.Method {
+	case "List":
+		entries, err := fs.ReadDir(h.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		infos := make(listerAt, 0, len(entries))
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, fileInfo{info})
+		}
+		return infos, nil
+
+	case "Stat":
+		info, err := fs.Stat(h.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{fileInfo{info}}, nil
+
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (h *handlers) Readlink(p string) (string, error) {
+	name := fsName(p)
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	if hdr, ok := info.Sys().(*tar.Header); ok {
+		if hdr.Typeflag != tar.TypeSymlink {
+			return "", &fs.PathError{Op: "readlink", Path: p, Err: errors.New("not a symbolic link")}
+		}
+		return hdr.Linkname, nil
+	}
+
+	return fs.ReadLink(h.fsys, name)
+}
+
+// fsName converts an absolute SFTP path to an fs.FS name.
+func fsName(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// seekReaderAt implements io.ReaderAt for files which only implement io.Seeker.
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+	f  fs.File
+}
+
+func (r *seekReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(r.rs, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *seekReaderAt) Close() error {
+	return r.f.Close()
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fileInfo exposes the owner of the files of a tar archive.
+type fileInfo struct {
+	fs.FileInfo
+}
+
+var _ sftp.FileInfoUidGid = fileInfo{}
+
+func (fi fileInfo) Uid() uint32 {
+	if h, ok := fi.Sys().(*tar.Header); ok {
+		return uint32(h.Uid)
+	}
+	return 0
+}
+
+func (fi fileInfo) Gid() uint32 {
+	if h, ok := fi.Sys().(*tar.Header); ok {
+		return uint32(h.Gid)
+	}
+	return 0
+}
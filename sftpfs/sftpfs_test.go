@@ -0,0 +1,168 @@
+package sftpfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/nlepage/go-tarfs"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(t *testing.T, fsys fs.FS) *sftp.Client {
+	t.Helper()
+
+	c, s := net.Pipe()
+
+	srv := sftp.NewRequestServer(s, Handlers(fsys))
+	go srv.Serve()
+	t.Cleanup(func() { srv.Close() })
+
+	client, err := sftp.NewClientPipe(c, c)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestHandlers(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := tarfs.NewFromFile("../test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	client := newClient(t, tfs)
+
+	infos, err := client.ReadDir("/dir1")
+	require.NoError(err)
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	assert.Equal([]string{"dir11", "file11", "file12"}, names)
+
+	info, err := client.Stat("/dir1/dir11/file111")
+	require.NoError(err)
+	assert.Equal("file111", info.Name())
+	assert.Equal(int64(7), info.Size())
+	assert.Equal(fs.FileMode(0644), info.Mode())
+
+	h, err := tfs.Open("dir1/dir11/file111")
+	require.NoError(err)
+	hi, err := h.Stat()
+	require.NoError(err)
+	require.NoError(h.Close())
+	hdr := hi.Sys().(*tar.Header)
+	assert.Equal(uint32(hdr.Uid), info.Sys().(*sftp.FileStat).UID)
+	assert.Equal(uint32(hdr.Gid), info.Sys().(*sftp.FileStat).GID)
+
+	_, err = client.Stat("/missing")
+	assert.ErrorIs(err, fs.ErrNotExist)
+
+	f, err := client.Open("/dir1/dir11/file111")
+	require.NoError(err)
+	defer f.Close()
+
+	b := make([]byte, 3)
+	n, err := f.ReadAt(b, 4)
+	require.NoError(err)
+	assert.Equal("111", string(b[:n]))
+
+	_, err = f.Seek(2, io.SeekStart)
+	require.NoError(err)
+	content, err := io.ReadAll(f)
+	require.NoError(err)
+	assert.Equal("le111", string(content))
+
+	_, err = client.Create("/new")
+	assert.ErrorIs(err, os.ErrPermission)
+	assert.ErrorIs(client.Mkdir("/new"), os.ErrPermission)
+	assert.ErrorIs(client.Remove("/foo"), os.ErrPermission)
+	assert.ErrorIs(client.Rename("/foo", "/new"), os.ErrPermission)
+}
+
+func TestHandlersSparse(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := tarfs.NewFromFile("../test-sparse.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	client := newClient(t, tfs)
+
+	f, err := client.Open("/file1")
+	require.NoError(err)
+	defer f.Close()
+
+	b := make([]byte, 20)
+	n, err := f.ReadAt(b, 999980)
+	require.NoError(err)
+	assert.Equal(append(make([]byte, 10), bytes.Repeat([]byte{1}, 10)...), b[:n])
+}
+
+func TestReadlink(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "target", Mode: 0777}))
+	require.NoError(tw.WriteHeader(&tar.Header{Name: "target", Typeflag: tar.TypeReg, Mode: 0644}))
+	require.NoError(tw.Close())
+
+	tfs, err := tarfs.New(&buf)
+	require.NoError(err)
+
+	client := newClient(t, tfs)
+
+	target, err := client.ReadLink("/link")
+	require.NoError(err)
+	assert.Equal("target", target)
+
+	_, err = client.ReadLink("/target")
+	assert.Error(err)
+}
+
+func TestSeekReaderAt(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	fsys := os.DirFS("..")
+
+	client := newClient(t, noReaderAtFS{fsys})
+
+	f, err := client.Open("/README.md")
+	require.NoError(err)
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	require.NoError(err)
+
+	expected, err := fs.ReadFile(fsys, "README.md")
+	require.NoError(err)
+	assert.Equal(expected, content)
+}
+
+// noReaderAtFS hides the ReadAt method of the files of an fs.FS.
+type noReaderAtFS struct {
+	fs.FS
+}
+
+func (fsys noReaderAtFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		fs.File
+		io.Seeker
+	}{f, f.(io.Seeker)}, nil
+}
@@ -0,0 +1,41 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"io/fs"
+)
+
+// specialEntry is a device, named pipe or socket, which has no content.
+type specialEntry struct {
+	*regEntry
+}
+
+var _ entry = &specialEntry{}
+
+func (e *specialEntry) readfile(path string) ([]byte, error) {
+	return nil, newErr("readfile", path, ErrSpecialFile)
+}
+
+func (e *specialEntry) open(path string) (*file, error) {
+	return nil, newErr("open", path, ErrSpecialFile)
+}
+
+// isSpecial reports whether mode is the mode of a device, named pipe or socket.
+func isSpecial(mode fs.FileMode) bool {
+	return mode&(fs.ModeDevice|fs.ModeNamedPipe|fs.ModeSocket) != 0
+}
+
+// Device returns the major and minor numbers of the character or block device described by info.
+// ok is false if info is not a device, or has no tar header.
+func Device(info fs.FileInfo) (major, minor int64, ok bool) {
+	h, _ := info.Sys().(*tar.Header)
+	return headerDevice(h)
+}
+
+// headerDevice returns the major and minor numbers of the character or block device described by h, which may be nil.
+func headerDevice(h *tar.Header) (major, minor int64, ok bool) {
+	if h == nil || (h.Typeflag != tar.TypeChar && h.Typeflag != tar.TypeBlock) {
+		return 0, 0, false
+	}
+	return h.Devmajor, h.Devminor, true
+}
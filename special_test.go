@@ -0,0 +1,108 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSpecialArchive(t *testing.T) []byte {
+	return newTestArchive(t,
+		testEntry{&tar.Header{Name: "dev/null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3, Mode: 0666}, ""},
+		testEntry{&tar.Header{Name: "dev/sda", Typeflag: tar.TypeBlock, Devmajor: 8, Devminor: 0, Mode: 0660}, ""},
+		testEntry{&tar.Header{Name: "run/fifo", Typeflag: tar.TypeFifo, Mode: 0644}, ""},
+		testEntry{&tar.Header{Name: "run/socket", Typeflag: tar.TypeReg, Mode: 0140755}, ""},
+		testEntry{&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644}, "file"},
+	)
+}
+
+func TestSpecialFiles(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := New(bytes.NewReader(newSpecialArchive(t)))
+	require.NoError(err)
+
+	for _, tc := range []struct {
+		name         string
+		mode         fs.FileMode
+		major, minor int64
+		isDevice     bool
+	}{
+		{"dev/null", fs.ModeDevice | fs.ModeCharDevice | 0666, 1, 3, true},
+		{"dev/sda", fs.ModeDevice | 0660, 8, 0, true},
+		{"run/fifo", fs.ModeNamedPipe | 0644, 0, 0, false},
+		{"run/socket", fs.ModeSocket | 0755, 0, 0, false},
+	} {
+		info, err := fs.Stat(tfs, tc.name)
+		require.NoError(err)
+		assert.Equal(tc.mode, info.Mode(), tc.name)
+
+		major, minor, ok := Device(info)
+		assert.Equal(tc.isDevice, ok, tc.name)
+		assert.Equal(tc.major, major, tc.name)
+		assert.Equal(tc.minor, minor, tc.name)
+
+		_, err = tfs.Open(tc.name)
+		assert.ErrorIs(err, ErrSpecialFile, tc.name)
+		var pe *fs.PathError
+		if assert.ErrorAs(err, &pe) {
+			assert.Equal("open", pe.Op)
+			assert.Equal(tc.name, pe.Path)
+		}
+
+		_, err = fs.ReadFile(tfs, tc.name)
+		assert.ErrorIs(err, ErrSpecialFile, tc.name)
+	}
+
+	entries, err := fs.ReadDir(tfs, "dev")
+	require.NoError(err)
+	require.Len(entries, 2)
+	assert.Equal(fs.ModeDevice|fs.ModeCharDevice, entries[0].Type())
+	assert.Equal(fs.ModeDevice, entries[1].Type())
+
+	sub, err := fs.Sub(tfs, "dev")
+	require.NoError(err)
+	_, err = sub.Open("null")
+	assert.ErrorIs(err, ErrSpecialFile)
+
+	info, err := fs.Stat(tfs, "file")
+	require.NoError(err)
+	_, _, ok := Device(info)
+	assert.False(ok)
+}
+
+func TestExtractSkipsSpecialFiles(t *testing.T) {
+	require := require.New(t)
+
+	tfs, err := New(bytes.NewReader(newSpecialArchive(t)))
+	require.NoError(err)
+
+	dest := t.TempDir()
+	require.NoError(Extract(tfs, dest, nil))
+
+	_, err = os.Lstat(filepath.Join(dest, "dev", "null"))
+	require.ErrorIs(err, fs.ErrNotExist)
+
+	b, err := os.ReadFile(filepath.Join(dest, "file"))
+	require.NoError(err)
+	require.Equal("file", string(b))
+}
+
+func TestHandlerSpecialFiles(t *testing.T) {
+	tfs, err := New(bytes.NewReader(newSpecialArchive(t)))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(Handler(tfs, nil))
+	defer srv.Close()
+
+	res, _ := get(t, srv, "/dev/null", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+}
@@ -0,0 +1,300 @@
+package tarfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strconv"
+)
+
+// SyncOptions configures Sync.
+type SyncOptions struct {
+	ExtractOptions
+
+	// Checksum compares the content of files instead of their modification time.
+	Checksum bool
+
+	// Delete removes the files of the destination directory which are not in the fs.FS.
+	Delete bool
+}
+
+// SyncReport lists the names of the files changed by Sync.
+type SyncReport struct {
+	Created []string
+	Updated []string
+	Deleted []string
+}
+
+// Sync updates the directory dest, which is created if needed, to match the contents of fsys.
+// Unlike Extract, only the files which differ from dest are written, each one atomically
+// by writing a temporary file which is renamed.
+// Regular files differ if their size, mode or modification time differ,
+// or their content if SyncOptions.Checksum is set.
+func Sync(fsys fs.FS, dest string, opts *SyncOptions) (*SyncReport, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	s := &syncer{
+		extractor: newExtractor(fsys, root, &opts.ExtractOptions),
+		opts:      opts,
+		report:    &SyncReport{},
+		names:     make(map[string]struct{}),
+	}
+
+	if err := s.sync(); err != nil {
+		return s.report, err
+	}
+
+	return s.report, nil
+}
+
+type syncer struct {
+	*extractor
+	opts   *SyncOptions
+	report *SyncReport
+	names  map[string]struct{} // names of the files of fsys
+}
+
+func (s *syncer) sync() error {
+	if err := fs.WalkDir(s.fsys, ".", s.walk); err != nil {
+		return err
+	}
+
+	for _, l := range s.links {
+		if err := s.link(l.name, l.info); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Delete {
+		if err := fs.WalkDir(s.root.FS(), ".", s.delete); err != nil {
+			return err
+		}
+	}
+
+	for i := len(s.dirs) - 1; i >= 0; i-- {
+		if err := s.finalizeDir(s.dirs[i].name, s.dirs[i].info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *syncer) walk(name string, d fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+
+	s.names[name] = struct{}{}
+
+	if name == "." {
+		return nil
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		if fi, err := s.root.Lstat(name); err != nil || !fi.IsDir() {
+			s.report.Created = append(s.report.Created, name)
+		}
+		if err := s.mkdir(name); err != nil {
+			return err
+		}
+		s.dirs = append(s.dirs, extracted{name, info})
+	case isHardLink(info) || info.Mode()&fs.ModeSymlink != 0:
+		s.links = append(s.links, extracted{name, info})
+	case info.Mode().IsRegular():
+		return s.writeFile(name, info)
+	}
+
+	return nil
+}
+
+func (s *syncer) writeFile(name string, info fs.FileInfo) error {
+	fi, err := s.root.Lstat(name)
+	if err == nil {
+		if unchanged, err := s.isUnchanged(name, info, fi); err != nil || unchanged {
+			return err
+		}
+	}
+
+	return s.replace(name, fi, func(tmp string) error {
+		dst, err := s.root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+
+		if err := s.copyFile(name, dst, info); err != nil {
+			dst.Close()
+			return err
+		}
+
+		if err := dst.Close(); err != nil {
+			return err
+		}
+
+		return s.chtimes(tmp, info)
+	})
+}
+
+// isUnchanged reports whether the regular file name of fsys, described by info,
+// is the same as the one of dest, described by fi.
+func (s *syncer) isUnchanged(name string, info, fi fs.FileInfo) (bool, error) {
+	if !fi.Mode().IsRegular() || fi.Size() != info.Size() || fi.Mode() != s.mode(info) {
+		return false, nil
+	}
+
+	if !s.opts.Checksum {
+		return fi.ModTime().Equal(info.ModTime()), nil
+	}
+
+	srcSum, err := checksum(s.fsys, name)
+	if err != nil {
+		return false, err
+	}
+
+	dstSum, err := checksum(s.root.FS(), name)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(srcSum, dstSum), nil
+}
+
+func (s *syncer) link(name string, info fs.FileInfo) error {
+	fi, err := s.root.Lstat(name)
+	if err == nil {
+		if unchanged, err := s.isLinkUnchanged(name, info, fi); err != nil || unchanged {
+			return err
+		}
+	}
+
+	return s.replace(name, fi, func(tmp string) error {
+		return s.createLink(name, tmp, info)
+	})
+}
+
+// isLinkUnchanged reports whether the symbolic or hard link name of fsys, described by info,
+// is the same as the one of dest, described by fi.
+func (s *syncer) isLinkUnchanged(name string, info, fi fs.FileInfo) (bool, error) {
+	if isHardLink(info) {
+		target, err := hardLinkTarget(name, info)
+		if err != nil {
+			return false, err
+		}
+		target, ok := s.linkTarget(name, target)
+		if !ok {
+			return false, nil
+		}
+		targetInfo, err := s.root.Lstat(target)
+		if err != nil {
+			return false, nil
+		}
+		return os.SameFile(fi, targetInfo), nil
+	}
+
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		return false, nil
+	}
+
+	target, err := readLink(s.fsys, name, info)
+	if err != nil {
+		return false, err
+	}
+
+	current, err := s.root.Readlink(name)
+	if err != nil {
+		return false, err
+	}
+
+	return current == target, nil
+}
+
+// replace atomically replaces the file name of dest, described by fi (nil if it does not exist),
+// by the temporary file created by create.
+func (s *syncer) replace(name string, fi fs.FileInfo, create func(tmp string) error) error {
+	tmp := path.Join(path.Dir(name), ".tarfs-"+strconv.FormatUint(rand.Uint64(), 36)+"-"+path.Base(name))
+
+	if err := create(tmp); err != nil {
+		s.root.Remove(tmp)
+		return err
+	}
+
+	if fi != nil && fi.IsDir() {
+		if err := s.root.RemoveAll(name); err != nil {
+			s.root.Remove(tmp)
+			return err
+		}
+	}
+
+	if err := s.root.Rename(tmp, name); err != nil {
+		s.root.Remove(tmp)
+		return err
+	}
+
+	if fi == nil {
+		s.report.Created = append(s.report.Created, name)
+	} else {
+		s.report.Updated = append(s.report.Updated, name)
+	}
+
+	return nil
+}
+
+func (s *syncer) delete(name string, d fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if _, ok := s.names[name]; ok {
+		return nil
+	}
+
+	if err := s.root.RemoveAll(name); err != nil {
+		return err
+	}
+
+	s.report.Deleted = append(s.report.Deleted, name)
+
+	if d.IsDir() {
+		return fs.SkipDir
+	}
+
+	return nil
+}
+
+// checksum returns the SHA-256 of the content of the file name of fsys.
+func checksum(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
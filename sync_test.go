@@ -0,0 +1,172 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	dest := t.TempDir()
+
+	report, err := Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Equal([]string{"bar", "dir1", "dir1/dir11", "dir1/dir11/file111", "dir1/file11", "dir1/file12", "dir2", "dir2/dir21", "dir2/dir21/file211", "dir2/dir21/file212", "foo"}, report.Created)
+	assert.Empty(report.Updated)
+	assert.Empty(report.Deleted)
+
+	content, err := os.ReadFile(filepath.Join(dest, "dir1", "dir11", "file111"))
+	require.NoError(err)
+	assert.Equal("file111", string(content))
+
+	report, err = Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{}, report)
+
+	require.NoError(os.WriteFile(filepath.Join(dest, "foo"), []byte("bar"), 0644))
+
+	report, err = Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{Updated: []string{"foo"}}, report)
+
+	content, err = os.ReadFile(filepath.Join(dest, "foo"))
+	require.NoError(err)
+	assert.Equal("foo", string(content))
+}
+
+func TestSyncChecksum(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	dest := t.TempDir()
+
+	_, err = Sync(tfs, dest, nil)
+	require.NoError(err)
+
+	fi, err := os.Stat(filepath.Join(dest, "foo"))
+	require.NoError(err)
+	require.NoError(os.WriteFile(filepath.Join(dest, "foo"), []byte("bar"), 0644))
+	require.NoError(os.Chtimes(filepath.Join(dest, "foo"), fi.ModTime(), fi.ModTime()))
+
+	report, err := Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{}, report)
+
+	report, err = Sync(tfs, dest, &SyncOptions{Checksum: true})
+	require.NoError(err)
+	assert.Equal(&SyncReport{Updated: []string{"foo"}}, report)
+
+	require.NoError(os.Chtimes(filepath.Join(dest, "foo"), time.Now(), time.Now()))
+
+	report, err = Sync(tfs, dest, &SyncOptions{Checksum: true})
+	require.NoError(err)
+	assert.Equal(&SyncReport{}, report)
+}
+
+func TestSyncDelete(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := NewFromFile("test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	dest := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dest, "extra"), []byte("extra"), 0644))
+	require.NoError(os.MkdirAll(filepath.Join(dest, "dir1", "extra", "dir"), 0755))
+
+	report, err := Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Empty(report.Deleted)
+
+	report, err = Sync(tfs, dest, &SyncOptions{Delete: true})
+	require.NoError(err)
+	assert.Equal(&SyncReport{Deleted: []string{"dir1/extra", "extra"}}, report)
+
+	_, err = os.Stat(filepath.Join(dest, "dir1", "extra"))
+	assert.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestSyncNestedHardLink(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := New(bytes.NewReader(newNestedHardLinkArchive(t)), WithNestedArchives())
+	require.NoError(err)
+
+	dest := t.TempDir()
+
+	_, err = Sync(tfs, dest, nil)
+	require.NoError(err)
+
+	content, err := os.ReadFile(filepath.Join(dest, "comp.tar", "link"))
+	require.NoError(err)
+	assert.Equal("INNER x", string(content))
+
+	report, err := Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{}, report)
+
+	// A link to the target of the same name outside of the nested archive is replaced
+	require.NoError(os.Remove(filepath.Join(dest, "comp.tar", "link")))
+	require.NoError(os.Link(filepath.Join(dest, "x"), filepath.Join(dest, "comp.tar", "link")))
+
+	report, err = Sync(tfs, dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{Updated: []string{"comp.tar/link"}}, report)
+
+	content, err = os.ReadFile(filepath.Join(dest, "comp.tar", "link"))
+	require.NoError(err)
+	assert.Equal("INNER x", string(content))
+}
+
+func TestSyncLinks(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	newFS := func(target string) fs.FS {
+		tfs, err := New(bytes.NewReader(newTestArchive(t,
+			testEntry{&tar.Header{Name: "file1", Typeflag: tar.TypeReg, Mode: 0644}, "file1"},
+			testEntry{&tar.Header{Name: "file2", Typeflag: tar.TypeReg, Mode: 0644}, "file2"},
+			testEntry{&tar.Header{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: target}, ""},
+			testEntry{&tar.Header{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: target}, ""},
+		)))
+		require.NoError(err)
+		return tfs
+	}
+
+	dest := t.TempDir()
+
+	report, err := Sync(newFS("file1"), dest, nil)
+	require.NoError(err)
+	assert.Equal([]string{"file1", "file2", "hardlink", "symlink"}, report.Created)
+
+	report, err = Sync(newFS("file1"), dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{}, report)
+
+	report, err = Sync(newFS("file2"), dest, nil)
+	require.NoError(err)
+	assert.Equal(&SyncReport{Updated: []string{"hardlink", "symlink"}}, report)
+
+	target, err := os.Readlink(filepath.Join(dest, "symlink"))
+	require.NoError(err)
+	assert.Equal("file2", target)
+
+	content, err := os.ReadFile(filepath.Join(dest, "hardlink"))
+	require.NoError(err)
+	assert.Equal("file2", string(content))
+}
@@ -0,0 +1,16 @@
+module github.com/nlepage/go-tarfs/webdavfs
+
+go 1.25.0
+
+require (
+	github.com/nlepage/go-tarfs v0.0.0-20261018212219-5d7dfdda1dcc
+	github.com/stretchr/testify v1.11.1
+	golang.org/x/net v0.58.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
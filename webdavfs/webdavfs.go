@@ -0,0 +1,172 @@
+// Package webdavfs exposes an fs.FS, such as one created by package tarfs, as a read-only webdav.FileSystem.
+package webdavfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nlepage/go-tarfs"
+	"golang.org/x/net/webdav"
+)
+
+// XattrNamespace is the XML namespace of the dead properties holding the extended attributes of a file.
+// An extended attribute "user.comment" is exposed as the property "user.comment" in this namespace.
+const XattrNamespace = "https://github.com/nlepage/go-tarfs/xattr"
+
+// FileSystem is a read-only webdav.FileSystem.
+// Methods modifying the file system return an error wrapping fs.ErrPermission.
+type FileSystem struct {
+	fsys fs.FS
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// New returns a FileSystem serving the contents of fsys.
+func New(fsys fs.FS) *FileSystem {
+	return &FileSystem{fsys}
+}
+
+const writeFlags = os.O_WRONLY | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+
+// Mkdir always returns an error wrapping fs.ErrPermission.
+func (*FileSystem) Mkdir(_ context.Context, name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+}
+
+// OpenFile opens the named file for reading.
+// It returns an error wrapping fs.ErrPermission if flag requests to create, truncate or write only.
+// O_RDWR is accepted, as webdav.Handler uses it to patch properties, but writes to the file fail.
+func (wfs *FileSystem) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	if flag&writeFlags != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+
+	f, err := wfs.fsys.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fsys: wfs.fsys, name: name}, nil
+}
+
+// RemoveAll always returns an error wrapping fs.ErrPermission.
+func (*FileSystem) RemoveAll(_ context.Context, name string) error {
+	return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrPermission}
+}
+
+// Rename always returns an error wrapping fs.ErrPermission.
+func (*FileSystem) Rename(_ context.Context, oldName, _ string) error {
+	return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+}
+
+// Stat returns a FileInfo describing the named file.
+func (wfs *FileSystem) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	return fs.Stat(wfs.fsys, fsName(name))
+}
+
+// fsName converts a slash-rooted webdav name to an fs.FS name.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+type file struct {
+	fs.File
+	fsys fs.FS
+	name string
+}
+
+var (
+	_ webdav.File            = (*file)(nil)
+	_ webdav.DeadPropsHolder = (*file)(nil)
+)
+
+func (f *file) Readdir(count int) ([]fs.FileInfo, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not implemented")}
+	}
+
+	entries, err := d.ReadDir(count)
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	s, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.New("not implemented")}
+	}
+	return s.Seek(offset, whence)
+}
+
+func (f *file) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+}
+
+// DeadProps returns the extended attributes of the file, see tarfs.Listxattr.
+// Values which are not valid UTF-8 are base64 encoded.
+func (f *file) DeadProps() (map[xml.Name]webdav.Property, error) {
+	name := fsName(f.name)
+
+	attrs, err := tarfs.Listxattr(f.fsys, name)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[xml.Name]webdav.Property, len(attrs))
+	for _, attr := range attrs {
+		value, err := tarfs.Getxattr(f.fsys, name, attr)
+		if err != nil {
+			return nil, err
+		}
+
+		xmlName := xml.Name{Space: XattrNamespace, Local: attr}
+		props[xmlName] = webdav.Property{XMLName: xmlName, InnerXML: propValue(value)}
+	}
+	return props, nil
+}
+
+func propValue(v []byte) []byte {
+	if !utf8.Valid(v) {
+		return []byte(base64.StdEncoding.EncodeToString(v))
+	}
+
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, v)
+	return b.Bytes()
+}
+
+// Patch refuses all the patches, the file system being read-only.
+func (f *file) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	pstat := webdav.Propstat{Status: http.StatusForbidden}
+	for _, p := range patches {
+		for _, prop := range p.Props {
+			pstat.Props = append(pstat.Props, webdav.Property{XMLName: prop.XMLName})
+		}
+	}
+	return []webdav.Propstat{pstat}, nil
+}
@@ -0,0 +1,167 @@
+package webdavfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nlepage/go-tarfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func newServer(t *testing.T, fsys fs.FS) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(&webdav.Handler{
+		FileSystem: New(fsys),
+		LockSystem: webdav.NewMemLS(),
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path string, header http.Header, body string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return res, string(b)
+}
+
+func TestHandler(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := tarfs.NewFromFile("../test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	srv := newServer(t, tfs)
+
+	res, body := do(t, srv, http.MethodGet, "/dir1/dir11/file111", nil, "")
+	require.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("file111", body)
+
+	res, body = do(t, srv, "PROPFIND", "/dir1/", http.Header{"Depth": {"1"}}, "")
+	require.Equal(http.StatusMultiStatus, res.StatusCode)
+	assert.Contains(body, "<D:href>/dir1/</D:href>")
+	assert.Contains(body, "<D:href>/dir1/dir11/</D:href>")
+	assert.Contains(body, "<D:href>/dir1/file11</D:href>")
+	assert.Contains(body, "<D:href>/dir1/file12</D:href>")
+
+	res, _ = do(t, srv, "PROPFIND", "/missing", http.Header{"Depth": {"0"}}, "")
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete, "MKCOL"} {
+		res, _ = do(t, srv, method, "/foo", nil, "")
+		assert.GreaterOrEqual(res.StatusCode, 400, method)
+	}
+
+	res, _ = do(t, srv, "MOVE", "/foo", http.Header{"Destination": {srv.URL + "/baz"}}, "")
+	assert.GreaterOrEqual(res.StatusCode, 400)
+
+	_, err = fs.Stat(tfs, "baz")
+	assert.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestFileSystem(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	tfs, err := tarfs.NewFromFile("../test.tar")
+	require.NoError(err)
+	defer tfs.Close()
+
+	wfs := New(tfs)
+	ctx := context.Background()
+
+	info, err := wfs.Stat(ctx, "/dir1/")
+	require.NoError(err)
+	assert.True(info.IsDir())
+
+	_, err = wfs.OpenFile(ctx, "/foo", os.O_WRONLY|os.O_TRUNC, 0)
+	assert.ErrorIs(err, fs.ErrPermission)
+
+	_, err = wfs.OpenFile(ctx, "/new", os.O_WRONLY|os.O_CREATE, 0644)
+	assert.ErrorIs(err, fs.ErrPermission)
+
+	assert.ErrorIs(wfs.Mkdir(ctx, "/new", 0755), fs.ErrPermission)
+	assert.ErrorIs(wfs.RemoveAll(ctx, "/foo"), fs.ErrPermission)
+	assert.ErrorIs(wfs.Rename(ctx, "/foo", "/new"), fs.ErrPermission)
+
+	f, err := wfs.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	require.NoError(err)
+	defer f.Close()
+
+	infos, err := f.Readdir(2)
+	require.NoError(err)
+	assert.Len(infos, 2)
+
+	infos, err = f.Readdir(0)
+	require.NoError(err)
+	assert.Len(infos, 2)
+
+	rw, err := wfs.OpenFile(ctx, "/foo", os.O_RDWR, 0)
+	require.NoError(err)
+	defer rw.Close()
+
+	_, err = rw.Write([]byte("x"))
+	assert.ErrorIs(err, fs.ErrPermission)
+}
+
+func TestDeadProps(t *testing.T) {
+	require, assert := require.New(t), assert.New(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "file",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     4,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.user.comment": "a <comment>",
+			"SCHILY.xattr.user.binary":  "\xff\x00",
+		},
+	}))
+	_, err := tw.Write([]byte("data"))
+	require.NoError(err)
+	require.NoError(tw.Close())
+
+	tfs, err := tarfs.New(&buf)
+	require.NoError(err)
+
+	srv := newServer(t, tfs)
+
+	res, body := do(t, srv, "PROPFIND", "/file", http.Header{"Depth": {"0"}}, `<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`)
+	require.Equal(http.StatusMultiStatus, res.StatusCode)
+	assert.Contains(body, `<user.comment xmlns="`+XattrNamespace+`">a &lt;comment&gt;</user.comment>`)
+	assert.Contains(body, `<user.binary xmlns="`+XattrNamespace+`">/wA=</user.binary>`)
+
+	res, body = do(t, srv, "PROPPATCH", "/file", nil, `<?xml version="1.0"?><D:propertyupdate xmlns:D="DAV:" xmlns:X="`+XattrNamespace+`"><D:set><D:prop><X:user.comment>changed</X:user.comment></D:prop></D:set></D:propertyupdate>`)
+	require.Equal(http.StatusMultiStatus, res.StatusCode)
+	assert.Contains(body, "403 Forbidden")
+
+	res, body = do(t, srv, "PROPFIND", "/file", http.Header{"Depth": {"0"}}, "")
+	require.Equal(http.StatusMultiStatus, res.StatusCode)
+	assert.Contains(body, "a &lt;comment&gt;")
+}
@@ -0,0 +1,162 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+const paxXattrPrefix = "SCHILY.xattr."
+
+// XattrFS is the interface implemented by a file system
+// providing the extended attributes of its files.
+// The FS created by New implements XattrFS.
+type XattrFS interface {
+	fs.FS
+
+	// Getxattr returns the value of the extended attribute attr of the file name.
+	// It returns an error wrapping ErrNoXattr if the file has no such attribute.
+	Getxattr(name, attr string) ([]byte, error)
+
+	// Listxattr returns the sorted names of the extended attributes of the file name.
+	Listxattr(name string) ([]string, error)
+}
+
+var _ XattrFS = &tarfs{}
+
+// Getxattr returns the value of the extended attribute attr of the file name,
+// read from the SCHILY.xattr PAX records of its header.
+func (tfs *tarfs) Getxattr(name, attr string) ([]byte, error) {
+	const op = "getxattr"
+
+	xattrs, err := tfs.xattrs(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := xattrs[attr]
+	if !ok {
+		return nil, newErr(op, name, ErrNoXattr)
+	}
+
+	return []byte(value), nil
+}
+
+// Listxattr returns the sorted names of the extended attributes of the file name,
+// read from the SCHILY.xattr PAX records of its header.
+func (tfs *tarfs) Listxattr(name string) ([]string, error) {
+	xattrs, err := tfs.xattrs("listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(xattrs), nil
+}
+
+func (tfs *tarfs) xattrs(op, name string) (map[string]string, error) {
+	e, err := tfs.get(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := e.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	// Implicit directories have no header
+	h, err := fileHeader(info)
+	if err != nil {
+		return nil, newErr(op, name, err)
+	}
+
+	return headerXattrs(h), nil
+}
+
+// Getxattr returns the value of the extended attribute attr of the file name of fsys.
+//
+// If fsys implements XattrFS, Getxattr calls fsys.Getxattr.
+// Otherwise the attribute is read from the *tar.Header returned by the Sys method of the file's fs.FileInfo,
+// and an error wrapping errors.ErrUnsupported is returned if there is none.
+func Getxattr(fsys fs.FS, name, attr string) ([]byte, error) {
+	const op = "getxattr"
+
+	if xfs, ok := fsys.(XattrFS); ok {
+		return xfs.Getxattr(name, attr)
+	}
+
+	xattrs, err := statXattrs(fsys, op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := xattrs[attr]
+	if !ok {
+		return nil, newErr(op, name, ErrNoXattr)
+	}
+
+	return []byte(value), nil
+}
+
+// Listxattr returns the sorted names of the extended attributes of the file name of fsys.
+//
+// If fsys implements XattrFS, Listxattr calls fsys.Listxattr.
+// Otherwise the attributes are read from the *tar.Header returned by the Sys method of the file's fs.FileInfo,
+// and an error wrapping errors.ErrUnsupported is returned if there is none.
+func Listxattr(fsys fs.FS, name string) ([]string, error) {
+	if xfs, ok := fsys.(XattrFS); ok {
+		return xfs.Listxattr(name)
+	}
+
+	xattrs, err := statXattrs(fsys, "listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(xattrs), nil
+}
+
+func statXattrs(fsys fs.FS, op, name string) (map[string]string, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	h, ok := info.Sys().(*tar.Header)
+	if !ok {
+		return nil, newErr(op, name, errors.ErrUnsupported)
+	}
+
+	return headerXattrs(h), nil
+}
+
+// headerXattrs returns the extended attributes recorded in h, which may be nil.
+// The PAX records take precedence over the deprecated Xattrs field.
+func headerXattrs(h *tar.Header) map[string]string {
+	xattrs := make(map[string]string)
+	if h == nil {
+		return xattrs
+	}
+
+	// Xattrs is deprecated, but may be set on headers created by hand
+	for k, v := range h.Xattrs {
+		xattrs[k] = v
+	}
+	for k, v := range h.PAXRecords {
+		if attr, ok := strings.CutPrefix(k, paxXattrPrefix); ok {
+			xattrs[attr] = v
+		}
+	}
+	return xattrs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXattr(t *testing.T) {
+	capability := "\x01\x00\x00\x02\x00\x04\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
+
+	tfs, err := New(bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "dir/ping", Typeflag: tar.TypeReg, Mode: 0755, Format: tar.FormatPAX, PAXRecords: map[string]string{
+			"SCHILY.xattr.security.capability": capability,
+			"SCHILY.xattr.security.selinux":    "system_u:object_r:ping_exec_t:s0",
+			"mtime":                            "1",
+		}}, "ping"},
+		testEntry{&tar.Header{Name: "dir/plain", Typeflag: tar.TypeReg, Mode: 0644}, "plain"},
+	)))
+	require.NoError(t, err)
+
+	sub, err := fs.Sub(tfs, "dir")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		fsys fs.FS
+		dir  string
+	}{
+		{"tarfs", tfs, "dir/"},
+		{"sub", sub, ""},
+		{"header", struct{ fs.FS }{tfs}, "dir/"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require, assert := require.New(t), assert.New(t)
+
+			attrs, err := Listxattr(tc.fsys, tc.dir+"ping")
+			require.NoError(err)
+			assert.Equal([]string{"security.capability", "security.selinux"}, attrs)
+
+			value, err := Getxattr(tc.fsys, tc.dir+"ping", "security.capability")
+			require.NoError(err)
+			assert.Equal([]byte(capability), value)
+
+			_, err = Getxattr(tc.fsys, tc.dir+"ping", "user.missing")
+			assert.ErrorIs(err, ErrNoXattr)
+
+			attrs, err = Listxattr(tc.fsys, tc.dir+"plain")
+			require.NoError(err)
+			assert.Empty(attrs)
+
+			_, err = Listxattr(tc.fsys, tc.dir+"missing")
+			assert.ErrorIs(err, fs.ErrNotExist)
+		})
+	}
+
+	// dir has no header
+	attrs, err := tfs.(XattrFS).Listxattr("dir")
+	require.NoError(t, err)
+	require.Empty(t, attrs)
+
+	require.NoError(t, tfs.Close())
+	_, err = tfs.(XattrFS).Listxattr("dir/ping")
+	require.ErrorIs(t, err, fs.ErrClosed)
+}
+
+func TestXattrHeaderReadError(t *testing.T) {
+	require := require.New(t)
+
+	ra := &failingReaderAt{bytes.NewReader(newTestArchive(t,
+		testEntry{&tar.Header{Name: "ping", Typeflag: tar.TypeReg, Mode: 0755, PAXRecords: map[string]string{
+			"SCHILY.xattr.security.capability": "cap",
+		}}, "ping"},
+	)), -1}
+
+	tfs, err := New(ra)
+	require.NoError(err)
+
+	ra.fail = 0
+	_, err = Listxattr(tfs, "ping")
+	require.ErrorIs(err, errFailingRead)
+
+	ra.fail = 0
+	_, err = Getxattr(tfs, "ping", "security.capability")
+	require.ErrorIs(err, errFailingRead)
+
+	value, err := Getxattr(tfs, "ping", "security.capability")
+	require.NoError(err)
+	require.Equal([]byte("cap"), value)
+}
+
+func TestXattrUnsupported(t *testing.T) {
+	_, err := Listxattr(os.DirFS("."), "test.tar")
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	_, err = Getxattr(os.DirFS("."), "test.tar", "user.name")
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}